@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"wallet/config"
+	"wallet/internal/service"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// runBackfill 补扫历史区块区间
+// 不读写扫块游标，已通知过的充值由充值存储去重。
+// 只有 sql 充值存储能与 worker 共享，memory / file 存储要求 worker 已停止；
+// 死信写入单独的文件（见 service.BackfillDeadLetterFile），用 cli replay -backfill 重放；
+// 只补扫已达到确认数的区块，补扫结束后没有进程继续推进确认数
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	configPath := fs.String("config", "config/config.yaml", "配置文件路径")
+	chainName := fs.String("chain", "", "链名称或链 ID（必填）")
+	from := fs.Uint64("from", 0, "起始区块（包含）")
+	to := fs.Uint64("to", 0, "结束区块（包含）")
+	handlers := fs.String("handlers", "", "只运行指定处理器，逗号分隔，例如 DepositHandler,TokenDepositHandler（默认全部）")
+	addresses := fs.String("address", "", "只补扫指定地址，逗号分隔（默认所有监控地址）")
+	fs.Parse(args)
+
+	if *chainName == "" || *to == 0 || *from > *to {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadWithEnv(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	chain := findChain(cfg, *chainName)
+	if chain == nil {
+		log.Fatalf("未找到链: %s", *chainName)
+	}
+
+	if cfg.Scanner.DepositStore != "sql" {
+		lock, err := service.LockWorker(cfg)
+		if err != nil {
+			log.Fatalf("充值存储为 %q 时不能与 worker 同时运行（worker 内存中的去重记录看不到补扫结果），请先停止 worker 或改用 sql 存储: %v",
+				cfg.Scanner.DepositStore, err)
+		}
+		defer lock.Unlock()
+	}
+	if cfg.Scanner.DepositStore == "" || cfg.Scanner.DepositStore == "memory" {
+		log.Printf("⚠️ 充值存储为 memory，补扫结果无法与 worker 之前通知过的充值去重")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// worker 可能同时在改写自己的死信文件
+	cfg.Scanner.DeadLetterFile = service.BackfillDeadLetterFile(cfg)
+	stores, err := service.OpenStores(ctx, cfg)
+	if err != nil {
+		log.Fatalf("创建存储失败: %v", err)
+	}
+	defer stores.Close()
+
+	svc, err := service.NewChainService(ctx, cfg, *chain, stores)
+	if err != nil {
+		log.Fatalf("创建扫块服务失败: %v", err)
+	}
+	defer svc.Close()
+
+	if *addresses != "" {
+		var list []common.Address
+		for _, addr := range splitList(*addresses) {
+			if !common.IsHexAddress(addr) {
+				log.Fatalf("地址格式错误: %s", addr)
+			}
+			list = append(list, common.HexToAddress(addr))
+		}
+		svc.Addresses.Replace(list)
+	}
+	if svc.Addresses.Len() == 0 {
+		log.Fatalf("没有监控地址：请用 -address 指定，或配置数据库存储从 addresses 表加载")
+	}
+
+	end, err := svc.Backfill(ctx, *from, *to, splitList(*handlers))
+	if err != nil {
+		log.Fatalf("补扫失败: %v", err)
+	}
+	fmt.Printf("%s: 区块 %d -> %d 补扫完成\n", chain.Name, *from, end)
+	if letters, err := stores.DeadLetters.List(ctx, uint64(chain.ChainID)); err == nil && len(letters) > 0 {
+		fmt.Printf("%s: %d 条处理失败写入 %s，请用 cli replay -backfill 重放\n", chain.Name, len(letters), cfg.Scanner.DeadLetterFile)
+	}
+	if end < *to {
+		fmt.Printf("%s: 区块 %d -> %d 尚未达到确认数，未补扫，请稍后再执行\n", chain.Name, end+1, *to)
+	}
+}
+
+// splitList 拆分逗号分隔的参数，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
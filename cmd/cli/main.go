@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"os"
 
 	"wallet/pkg/gas"
 
@@ -16,12 +17,40 @@ import (
 )
 
 func main() {
-	// 选择你要测试的功能
-	// 取消注释你想运行的示例：
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "gas":
+		exampleEstimateGas()
+	case "send":
+		exampleSendTransaction()
+	case "compare":
+		exampleCompareSpeed()
+	case "replay":
+		runReplay(os.Args[2:])
+	case "backfill":
+		runBackfill(os.Args[2:])
+	case "withdraw":
+		runWithdraw(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Println(`用法: cli <命令> [参数]
 
-	exampleEstimateGas()
-	exampleSendTransaction()
-	exampleCompareSpeed()
+命令:
+  gas       估算 Gas 参数（不发送交易）
+  send      发送一笔测试交易（需要私钥）
+  compare   对比不同速度档位的 Gas
+  replay    重放扫块死信（cli replay -h 查看参数）
+  backfill  补扫历史区块区间（cli backfill -h 查看参数）
+  withdraw  热钱包出账（cli withdraw -h 查看参数）`)
 }
 
 // 示例1：仅估算 gas 参数（不发送交易）
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+
+	"wallet/config"
+	"wallet/internal/service"
+)
+
+// runReplay 重放扫块死信
+// 死信文件和文件充值存储只能由一个进程写入，重放前要求 worker 已停止
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := fs.String("config", "config/config.yaml", "配置文件路径")
+	chainName := fs.String("chain", "", "只重放指定链（名称或链 ID），默认所有链")
+	backfill := fs.Bool("backfill", false, "重放 cli backfill 写入的死信")
+	fs.Parse(args)
+
+	cfg, err := config.LoadWithEnv(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if *backfill {
+		cfg.Scanner.DeadLetterFile = service.BackfillDeadLetterFile(cfg)
+	}
+
+	lock, err := service.LockWorker(cfg)
+	if err != nil {
+		log.Fatalf("重放会改写死信文件，请先停止 worker: %v", err)
+	}
+	defer lock.Unlock()
+
+	ctx := context.Background()
+	stores, err := service.OpenStores(ctx, cfg)
+	if err != nil {
+		log.Fatalf("创建存储失败: %v", err)
+	}
+	defer stores.Close()
+
+	for _, chain := range cfg.Chains {
+		if *chainName != "" && *chainName != chain.Name && *chainName != strconv.FormatInt(chain.ChainID, 10) {
+			continue
+		}
+
+		letters, err := stores.DeadLetters.List(ctx, uint64(chain.ChainID))
+		if err != nil {
+			log.Fatalf("读取死信失败: %v", err)
+		}
+		if len(letters) == 0 {
+			fmt.Printf("%s: 没有死信\n", chain.Name)
+			continue
+		}
+
+		svc, err := service.NewChainService(ctx, cfg, chain, stores)
+		if err != nil {
+			log.Printf("创建扫块服务失败 (%s): %v", chain.Name, err)
+			continue
+		}
+
+		result, err := svc.ReplayDeadLetters(ctx, stores.DeadLetters)
+		svc.Close()
+		if err != nil {
+			log.Printf("重放死信失败 (%s): %v", chain.Name, err)
+		}
+		if result != nil {
+			fmt.Printf("%s: 成功 %d, 失败 %d, 已重组丢弃 %d, 无处理器 %d\n",
+				chain.Name, result.Replayed, result.Failed, result.Dropped, result.Skipped)
+		}
+	}
+}
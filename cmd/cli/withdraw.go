@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"wallet/config"
+	"wallet/internal/service"
+	"wallet/internal/transfer"
+	"wallet/pkg/gas"
+	"wallet/pkg/signer"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// runWithdraw 从热钱包出账一笔（原生币或配置中的代币）
+// nonce 持久化到 transfer.nonce_file，出账记录写入 transfer.tx_store_file（worker 据此跟踪），
+// keystore 密码从环境变量 WALLET_KEYSTORE_PASSPHRASE 读取
+func runWithdraw(args []string) {
+	fs := flag.NewFlagSet("withdraw", flag.ExitOnError)
+	configPath := fs.String("config", "config/config.yaml", "配置文件路径")
+	chainName := fs.String("chain", "", "链名称或链 ID（必填）")
+	id := fs.String("id", "", "提现单号（必填，用于去重）")
+	from := fs.String("from", "", "热钱包地址（必填）")
+	to := fs.String("to", "", "收款地址（必填）")
+	amount := fs.String("amount", "", "金额，按代币精度换算，例如 1.5（必填）")
+	tokenName := fs.String("token", "", "代币符号或合约地址（默认原生币）")
+	keystoreDir := fs.String("keystore", "keystore", "keystore 目录")
+	speed := fs.String("speed", string(gas.Normal), "速度档位: slow, normal, fast")
+	fs.Parse(args)
+
+	if *chainName == "" || *id == "" || !common.IsHexAddress(*from) || !common.IsHexAddress(*to) || *amount == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadWithEnv(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	chain := findChain(cfg, *chainName)
+	if chain == nil {
+		log.Fatalf("未找到链: %s", *chainName)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// 只打开出账记录，充值等文件存储归 worker 所有
+	txs, err := service.OpenOutboundStore(cfg)
+	if err != nil {
+		log.Fatalf("创建存储失败: %v", err)
+	}
+
+	svc, err := service.NewTransferService(ctx, cfg, *chain, txs)
+	if err != nil {
+		log.Fatalf("创建出账服务失败: %v", err)
+	}
+	defer svc.Close()
+
+	var (
+		token    *common.Address
+		decimals uint8 = 18
+	)
+	if *tokenName != "" {
+		addr, err := findToken(*chain, *tokenName)
+		if err != nil {
+			log.Fatalf("代币错误: %v", err)
+		}
+		token = &addr
+		if decimals, err = svc.Transfer.TokenDecimals(ctx, addr); err != nil {
+			log.Fatalf("查询代币精度失败: %v", err)
+		}
+	}
+	value, err := transfer.ParseUnits(*amount, decimals)
+	if err != nil {
+		log.Fatalf("金额格式错误: %v", err)
+	}
+
+	s, err := signer.NewKeystore(*keystoreDir, common.HexToAddress(*from), os.Getenv("WALLET_KEYSTORE_PASSPHRASE"))
+	if err != nil {
+		log.Fatalf("打开 keystore 失败: %v", err)
+	}
+	defer s.Lock()
+
+	result, err := svc.Transfer.Execute(ctx, transfer.Request{
+		ID:     *id,
+		From:   s.Address(),
+		Signer: s,
+		To:     common.HexToAddress(*to),
+		Token:  token,
+		Amount: value,
+		Speed:  gas.Speed(*speed),
+	})
+	if err != nil {
+		log.Fatalf("出账失败: %v", err)
+	}
+	fmt.Printf("%s: 出账 %s 完成, tx=%s, block=%d, success=%v\n",
+		chain.Name, result.ID, result.TxHash.Hex(), result.BlockNumber, result.Success)
+}
+
+// findChain 按名称或链 ID 查找链配置
+func findChain(cfg *config.Config, name string) *config.ChainConfig {
+	for i := range cfg.Chains {
+		c := &cfg.Chains[i]
+		if name == c.Name || name == strconv.FormatInt(c.ChainID, 10) {
+			return c
+		}
+	}
+	return nil
+}
+
+// findToken 按符号或地址查找配置中的代币，只允许从白名单代币出账
+func findToken(chain config.ChainConfig, name string) (common.Address, error) {
+	for _, t := range chain.Tokens {
+		if strings.EqualFold(name, t.Symbol) || strings.EqualFold(name, t.Address) {
+			return common.HexToAddress(t.Address), nil
+		}
+	}
+	return common.Address{}, fmt.Errorf("%s 不在链 %s 的代币配置中", name, chain.Name)
+}
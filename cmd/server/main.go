@@ -7,6 +7,7 @@ import (
 
 	"wallet/api/http/handler"
 	"wallet/config"
+	"wallet/internal/metrics"
 )
 
 func main() {
@@ -24,6 +25,11 @@ func main() {
 	// 健康检查
 	mux.HandleFunc("/health", handler.Health)
 
+	// 监控指标
+	if cfg.Metrics.Enabled {
+		mux.Handle("/metrics", metrics.Handler())
+	}
+
 	// API 路由（示例）
 	mux.HandleFunc("/api/v1/balance", handler.GetBalance)
 	mux.HandleFunc("/api/v1/transfer", handler.Transfer)
@@ -3,13 +3,15 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"wallet/config"
-	"wallet/internal/scanner"
+	"wallet/internal/metrics"
+	"wallet/internal/service"
 )
 
 func main() {
@@ -26,11 +28,37 @@ func main() {
 		return
 	}
 
+	// 文件存储只能由一个进程写入，cli backfill / replay 通过这把锁判断 worker 是否在运行
+	lock, err := service.LockWorker(cfg)
+	if err != nil {
+		log.Fatalf("获取 worker 进程锁失败（是否已有 worker 在运行？）: %v", err)
+	}
+	defer lock.Unlock()
+
 	// 2. 创建上下文（支持优雅退出）
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// 3. 启动扫块器
+	// 3. 扫块游标、充值和死信存储
+	stores, err := service.OpenStores(ctx, cfg)
+	if err != nil {
+		log.Fatalf("创建存储失败: %v", err)
+	}
+	defer stores.Close()
+
+	// 4. 监控指标
+	if cfg.Metrics.Enabled {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			log.Printf("指标服务运行在: http://%s/metrics", cfg.Metrics.Addr)
+			if err := http.ListenAndServe(cfg.Metrics.Addr, mux); err != nil {
+				log.Printf("指标服务启动失败: %v", err)
+			}
+		}()
+	}
+
+	// 5. 启动扫块器
 	for _, chain := range cfg.Chains {
 		if len(chain.RPCURLs) == 0 {
 			log.Printf("跳过链 %s: 没有配置 RPC", chain.Name)
@@ -39,42 +67,22 @@ func main() {
 
 		log.Printf("启动扫块器: %s (chainID=%d)", chain.Name, chain.ChainID)
 
-		// 创建扫块器
-		s, err := scanner.New(scanner.Config{
-			RPCUrl:        chain.RPCURLs[0],
-			StartBlock:    cfg.Scanner.StartBlock,
-			ConfirmBlocks: cfg.Scanner.ConfirmBlocks,
-			BatchSize:     cfg.Scanner.BatchSize,
-		})
+		svc, err := service.NewChainService(ctx, cfg, chain, stores)
 		if err != nil {
-			log.Printf("创建扫块器失败: %v", err)
+			log.Printf("创建扫块服务失败: %v", err)
 			continue
 		}
-
-		// 添加充值处理器（示例）
-		depositHandler := scanner.NewDepositHandler(
-			[]string{}, // 这里添加需要监控的地址
-			func(deposit *scanner.Deposit) {
-				// 处理充值逻辑
-				log.Printf("💰 新充值: from=%s, amount=%s ETH, tx=%s",
-					deposit.From.Hex(),
-					weiToEth(deposit.Value),
-					deposit.TxHash.Hex(),
-				)
-				// TODO: 保存到数据库、发送通知等
-			},
-		)
-		s.AddHandler(depositHandler)
+		defer svc.Close()
 
 		// 启动扫块（在 goroutine 中运行）
-		go func(name string, scanner *scanner.Scanner) {
-			if err := scanner.Start(ctx, cfg.Scanner.ScanInterval); err != nil {
+		go func(name string, svc *service.ChainService) {
+			if err := svc.Run(ctx); err != nil {
 				log.Printf("扫块器 %s 错误: %v", name, err)
 			}
-		}(chain.Name, s)
+		}(chain.Name, svc)
 	}
 
-	// 4. 等待退出信号
+	// 6. 等待退出信号
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -86,9 +94,3 @@ func main() {
 
 	log.Println("Worker 已关闭")
 }
-
-func weiToEth(wei interface{}) string {
-	// 简化版本，实际应该使用 big.Int
-	
-	return "0.00"
-}
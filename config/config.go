@@ -13,9 +13,12 @@ type Config struct {
 	Server   ServerConfig   `yaml:"server"`
 	Database DatabaseConfig `yaml:"database"`
 	Chains   []ChainConfig  `yaml:"chains"`
+	RPC      RPCConfig      `yaml:"rpc"`
 	Scanner  ScannerConfig  `yaml:"scanner"`
+	Transfer TransferConfig `yaml:"transfer"`
 	Collect  CollectConfig  `yaml:"collect"`
 	Risk     RiskConfig     `yaml:"risk"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
 }
 
 // ServerConfig API 服务器配置
@@ -28,7 +31,7 @@ type ServerConfig struct {
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	Driver   string `yaml:"driver"` // postgres, mysql, sqlite
+	Driver   string `yaml:"driver"` // postgres, mysql（sqlite 需另行导入驱动，见 internal/service/drivers.go）
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	User     string `yaml:"user"`
@@ -37,23 +40,83 @@ type DatabaseConfig struct {
 	MaxConns int    `yaml:"max_conns"`
 }
 
+// DSN 按驱动生成连接串（sqlite 时 Database 为文件路径）
+func (c DatabaseConfig) DSN() string {
+	switch c.Driver {
+	case "mysql":
+		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", c.User, c.Password, c.Host, c.Port, c.Database)
+	case "sqlite":
+		return c.Database
+	default:
+		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+			c.Host, c.Port, c.User, c.Password, c.Database)
+	}
+}
+
 // ChainConfig 区块链配置
 type ChainConfig struct {
-	ChainID   int64    `yaml:"chain_id"`
-	Name      string   `yaml:"name"` // eth, bsc, polygon
-	RPCURLs   []string `yaml:"rpc_urls"`
-	WSURLs    []string `yaml:"ws_urls"`
-	IsTestnet bool     `yaml:"is_testnet"`
+	ChainID       int64         `yaml:"chain_id"`
+	Name          string        `yaml:"name"` // eth, bsc, polygon
+	RPCURLs       []string      `yaml:"rpc_urls"`
+	WSURLs        []string      `yaml:"ws_urls"`
+	IsTestnet     bool          `yaml:"is_testnet"`
+	Tokens        []TokenConfig `yaml:"tokens"`         // 允许入账的代币合约
+	TraceInternal bool          `yaml:"trace_internal"` // 追踪内部转账（需要节点支持 debug_traceBlockByNumber）
+	ScanMode      string        `yaml:"scan_mode"`      // blocks（默认）或 logs（只收代币时用 eth_getLogs）
+}
+
+// TokenConfig 代币配置
+type TokenConfig struct {
+	Symbol   string `yaml:"symbol"`
+	Address  string `yaml:"address"`
+	Decimals uint8  `yaml:"decimals"`
+}
+
+// RPCConfig 多节点 RPC 连接池配置（每条链的 rpc_urls 共用）
+type RPCConfig struct {
+	Strategy            string        `yaml:"strategy"`              // 节点选择: priority, round_robin, fastest
+	MaxLag              uint64        `yaml:"max_lag"`               // 链头落后超过该区块数的节点暂不使用
+	MaxFailures         int           `yaml:"max_failures"`          // 连续失败多少次后剔除节点
+	EjectDuration       time.Duration `yaml:"eject_duration"`        // 剔除时长
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"` // 健康检查间隔
 }
 
 // ScannerConfig 扫块配置
 type ScannerConfig struct {
-	Enabled          bool          `yaml:"enabled"`
-	StartBlock       uint64        `yaml:"start_block"`        // 起始区块（0 表示最新）
-	ConfirmBlocks    uint64        `yaml:"confirm_blocks"`     // 确认区块数
-	BatchSize        int           `yaml:"batch_size"`         // 批量扫描大小
-	ScanInterval     time.Duration `yaml:"scan_interval"`      // 扫描间隔
-	ConcurrentChains int           `yaml:"concurrent_chains"`  // 并发扫描链数
+	Enabled             bool          `yaml:"enabled"`
+	StartBlock          uint64        `yaml:"start_block"`           // 起始区块（0 表示最新）
+	ConfirmBlocks       uint64        `yaml:"confirm_blocks"`        // 确认区块数
+	BatchSize           int           `yaml:"batch_size"`            // 批量扫描大小
+	ScanInterval        time.Duration `yaml:"scan_interval"`         // 扫描间隔
+	ConcurrentChains    int           `yaml:"concurrent_chains"`     // 并发扫描链数
+	CursorStore         string        `yaml:"cursor_store"`          // 游标存储: file, sql（空表示不持久化）
+	CursorDir           string        `yaml:"cursor_dir"`            // 文件游标目录
+	ReorgDepth          uint64        `yaml:"reorg_depth"`           // 重组检测窗口（区块数）
+	FetchConcurrency    int           `yaml:"fetch_concurrency"`     // 单链并发预取区块数
+	DepositStore        string        `yaml:"deposit_store"`         // 充值去重存储: memory, file, sql
+	DepositFile         string        `yaml:"deposit_file"`          // 文件充值存储路径
+	AddressSyncInterval time.Duration `yaml:"address_sync_interval"` // 监控地址增量同步间隔
+
+	ErrorPolicy     ErrorPolicyConfig            `yaml:"error_policy"`     // 处理器默认错误策略
+	HandlerPolicies map[string]ErrorPolicyConfig `yaml:"handler_policies"` // 按处理器类型名单独设置，例如 DepositHandler
+	DeadLetterFile  string                       `yaml:"dead_letter_file"` // 死信文件路径
+	LogRange        uint64                       `yaml:"log_range"`        // logs 模式单次 eth_getLogs 的最大区块跨度
+	LockFile        string                       `yaml:"lock_file"`        // worker 进程锁，cli 据此判断 worker 是否在运行
+}
+
+// ErrorPolicyConfig 处理器错误策略
+type ErrorPolicyConfig struct {
+	Retries   int           `yaml:"retries"`    // 重试次数
+	Backoff   time.Duration `yaml:"backoff"`    // 首次重试间隔（之后翻倍）
+	OnFailure string        `yaml:"on_failure"` // 重试耗尽后: skip, halt, dead_letter
+}
+
+// TransferConfig 出账配置
+type TransferConfig struct {
+	TxStoreFile   string        `yaml:"tx_store_file"`  // 出账交易记录文件
+	NonceFile     string        `yaml:"nonce_file"`     // 热钱包 nonce 持久化文件
+	Confirmations uint64        `yaml:"confirmations"`  // 出账交易确认数
+	TrackInterval time.Duration `yaml:"track_interval"` // worker 检查未完成出账交易的间隔
 }
 
 // CollectConfig 归集配置
@@ -76,6 +139,13 @@ type RiskConfig struct {
 	RequireManualApproval bool `yaml:"require_manual_approval"` // 大额需人工审批
 }
 
+// MetricsConfig Prometheus 指标配置
+// API 服务直接在自身端口暴露 /metrics，worker 单独监听 Addr
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"` // worker 指标监听地址，例如 ":9100"
+}
+
 // Load 加载配置文件
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
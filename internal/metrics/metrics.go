@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 扫块指标（按 chain 标签区分，值为链 ID）
+var (
+	// HeadBlock 节点最新区块高度
+	HeadBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "wallet",
+		Subsystem: "scanner",
+		Name:      "head_block",
+		Help:      "Latest block number reported by the RPC node.",
+	}, []string{"chain"})
+
+	// ScannedBlock 已处理完的区块高度
+	ScannedBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "wallet",
+		Subsystem: "scanner",
+		Name:      "scanned_block",
+		Help:      "Last block fully processed by the scanner.",
+	}, []string{"chain"})
+
+	// LagBlocks 扫块延迟（链头 - 已处理高度）
+	LagBlocks = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "wallet",
+		Subsystem: "scanner",
+		Name:      "lag_blocks",
+		Help:      "Number of blocks between the chain head and the last processed block.",
+	}, []string{"chain"})
+
+	// BlocksPerSecond 最近一轮扫描的吞吐
+	BlocksPerSecond = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "wallet",
+		Subsystem: "scanner",
+		Name:      "blocks_per_second",
+		Help:      "Scan throughput of the most recent scan round.",
+	}, []string{"chain"})
+
+	// BlocksScanned 累计处理的区块数（可用 rate() 计算长期吞吐）
+	BlocksScanned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Subsystem: "scanner",
+		Name:      "blocks_scanned_total",
+		Help:      "Total number of blocks processed by the scanner.",
+	}, []string{"chain"})
+
+	// ScannerHalted 扫块器已停止（reason: deep_reorg / handler），需要人工处理
+	ScannerHalted = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "wallet",
+		Subsystem: "scanner",
+		Name:      "halted",
+		Help:      "Set to 1 when the scanner has stopped and needs manual intervention.",
+	}, []string{"chain", "reason"})
+
+	// HandlerDuration 处理器耗时（kind: block / tx）
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "wallet",
+		Subsystem: "scanner",
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent in scanner handlers.",
+		Buckets:   []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"chain", "handler", "kind"})
+)
+
+// RPC 指标
+var (
+	// RPCErrors RPC 调用失败次数
+	RPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Subsystem: "rpc",
+		Name:      "errors_total",
+		Help:      "RPC call failures by method and endpoint.",
+	}, []string{"chain", "method", "endpoint"})
+)
+
+// RPCErrorObserver 生成 rpcpool.Config.OnError 回调
+// endpoint 只保留主机名，避免 URL 里的 API Key 出现在指标中
+func RPCErrorObserver(chain string) func(method, endpoint string, err error) {
+	return func(method, endpoint string, err error) {
+		if u, perr := url.Parse(endpoint); perr == nil && u.Host != "" {
+			endpoint = u.Host
+		}
+		RPCErrors.WithLabelValues(chain, method, endpoint).Inc()
+	}
+}
+
+// Handler /metrics 处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,282 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/maphash"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AddressSet 并发安全的监控地址集合
+//
+// 扫块时绝大多数交易都与我们无关，查询先走无锁的布隆过滤器，
+// 只有可能命中时才加读锁查 map，运行时增删地址不会阻塞扫块。
+// 布隆过滤器按容量一次性分配，Add 后地址数超过容量时按两倍容量重建。
+type AddressSet struct {
+	bloom atomic.Pointer[bloomFilter]
+
+	mu    sync.RWMutex
+	addrs map[common.Address]struct{}
+}
+
+// NewAddressSet 创建地址集合
+func NewAddressSet(addresses ...string) *AddressSet {
+	list := make([]common.Address, 0, len(addresses))
+	for _, addr := range addresses {
+		list = append(list, common.HexToAddress(addr))
+	}
+
+	s := &AddressSet{}
+	s.Replace(list)
+	return s
+}
+
+// Contains 地址是否在监控中
+func (s *AddressSet) Contains(addr common.Address) bool {
+	if !s.bloom.Load().mayContain(addr) {
+		return false
+	}
+
+	s.mu.RLock()
+	_, ok := s.addrs[addr]
+	s.mu.RUnlock()
+	return ok
+}
+
+// Add 添加监控地址
+func (s *AddressSet) Add(addrs ...common.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bloom := s.bloom.Load()
+	for _, addr := range addrs {
+		s.addrs[addr] = struct{}{}
+		bloom.add(addr)
+	}
+
+	// 超过容量后误判率快速上升，重建期间旧过滤器仍然有效（只多不少）
+	if len(s.addrs) > bloom.capacity {
+		bloom = newBloomFilter(len(s.addrs) * 2)
+		for addr := range s.addrs {
+			bloom.add(addr)
+		}
+		s.bloom.Store(bloom)
+	}
+}
+
+// Remove 移除监控地址（布隆过滤器不支持删除，只影响误判率）
+func (s *AddressSet) Remove(addrs ...common.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, addr := range addrs {
+		delete(s.addrs, addr)
+	}
+}
+
+// Replace 整体替换地址集合（批量加载）
+// 新集合在锁外构建，只在最后交换指针时短暂持有写锁
+func (s *AddressSet) Replace(addrs []common.Address) {
+	m := make(map[common.Address]struct{}, len(addrs))
+	bloom := newBloomFilter(len(addrs) * 2)
+	for _, addr := range addrs {
+		m[addr] = struct{}{}
+		bloom.add(addr)
+	}
+
+	s.mu.Lock()
+	s.addrs = m
+	s.bloom.Store(bloom)
+	s.mu.Unlock()
+}
+
+// List 所有地址（无序）
+func (s *AddressSet) List() []common.Address {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]common.Address, 0, len(s.addrs))
+	for addr := range s.addrs {
+		list = append(list, addr)
+	}
+	return list
+}
+
+// Len 地址数量
+func (s *AddressSet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.addrs)
+}
+
+// bloomFilter 无锁布隆过滤器（位数组用原子操作读写）
+type bloomFilter struct {
+	bits     []atomic.Uint64
+	k        uint64 // 哈希函数个数
+	seed     maphash.Seed
+	capacity int // 设计容量，超过后需要重建
+}
+
+// minBloomCapacity 最小容量，避免小集合频繁误判
+const minBloomCapacity = 1 << 16
+
+// newBloomFilter 按容量创建，约 10 bit/元素、7 个哈希，误判率约 1%
+func newBloomFilter(capacity int) *bloomFilter {
+	if capacity < minBloomCapacity {
+		capacity = minBloomCapacity
+	}
+	words := (capacity*10 + 63) / 64
+	return &bloomFilter{
+		bits:     make([]atomic.Uint64, words),
+		k:        7,
+		seed:     maphash.MakeSeed(),
+		capacity: capacity,
+	}
+}
+
+func (f *bloomFilter) add(addr common.Address) {
+	h1, h2 := f.hash(addr)
+	size := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % size
+		word := &f.bits[bit/64]
+		mask := uint64(1) << (bit % 64)
+		for {
+			old := word.Load()
+			if old&mask != 0 || word.CompareAndSwap(old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+func (f *bloomFilter) mayContain(addr common.Address) bool {
+	h1, h2 := f.hash(addr)
+	size := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % size
+		if f.bits[bit/64].Load()&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hash 双重哈希（Kirsch-Mitzenmacher），h2 取奇数保证步长有效
+func (f *bloomFilter) hash(addr common.Address) (uint64, uint64) {
+	h := maphash.Bytes(f.seed, addr[:])
+	return h, (h>>32 | h<<32) | 1
+}
+
+// addressIDMargin 增量同步时回看的 id 范围
+// 自增 id 在插入时分配、提交时才可见，并发写入的事务可能晚于更大的 id 提交，
+// 只查 id > lastID 会永远漏掉这些地址，所以每次都重新查询最近的一段
+const addressIDMargin = 1000
+
+// SQLAddressLoader 从 addresses 表加载监控地址
+// API 服务写入新地址后，worker 通过 Run 增量同步
+type SQLAddressLoader struct {
+	db      *sql.DB
+	driver  string
+	chainID int64
+	lastID  int64 // 已加载的最大 id
+}
+
+// NewSQLAddressLoader 创建地址加载器
+func NewSQLAddressLoader(db *sql.DB, driver string, chainID int64) *SQLAddressLoader {
+	return &SQLAddressLoader{db: db, driver: driver, chainID: chainID}
+}
+
+// LoadAll 全量加载并替换集合
+func (l *SQLAddressLoader) LoadAll(ctx context.Context, set *AddressSet) error {
+	addrs, lastID, err := l.query(ctx, 0)
+	if err != nil {
+		return err
+	}
+	set.Replace(addrs)
+	l.lastID = lastID
+	return nil
+}
+
+// LoadNew 增量加载新地址（从上次最大 id 往前 addressIDMargin 开始），返回新加入的数量
+func (l *SQLAddressLoader) LoadNew(ctx context.Context, set *AddressSet) (int, error) {
+	afterID := l.lastID - addressIDMargin
+	if afterID < 0 {
+		afterID = 0
+	}
+	addrs, lastID, err := l.query(ctx, afterID)
+	if err != nil {
+		return 0, err
+	}
+
+	var added []common.Address
+	for _, addr := range addrs {
+		if !set.Contains(addr) {
+			added = append(added, addr)
+		}
+	}
+	set.Add(added...)
+	if lastID > l.lastID {
+		l.lastID = lastID
+	}
+	return len(added), nil
+}
+
+// Run 定期增量同步，直到 ctx 取消
+func (l *SQLAddressLoader) Run(ctx context.Context, set *AddressSet, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := l.LoadNew(ctx, set)
+			if err != nil {
+				log.Printf("同步监控地址失败 (chainID=%d): %v", l.chainID, err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("新增监控地址 %d 个 (chainID=%d, 总数=%d)", n, l.chainID, set.Len())
+			}
+		}
+	}
+}
+
+func (l *SQLAddressLoader) query(ctx context.Context, afterID int64) ([]common.Address, int64, error) {
+	rows, err := l.db.QueryContext(ctx,
+		rebind(l.driver, "SELECT id, address FROM addresses WHERE chain_id = ? AND id > ? ORDER BY id"),
+		l.chainID, afterID,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query addresses: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		addrs  []common.Address
+		lastID = afterID
+	)
+	for rows.Next() {
+		var (
+			id   int64
+			addr string
+		)
+		if err := rows.Scan(&id, &addr); err != nil {
+			return nil, 0, fmt.Errorf("scan address: %w", err)
+		}
+		addrs = append(addrs, common.HexToAddress(addr))
+		lastID = id
+	}
+	return addrs, lastID, rows.Err()
+}
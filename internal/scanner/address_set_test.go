@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// testAddress 第 i 个测试地址
+func testAddress(i int) common.Address {
+	return common.BigToAddress(big.NewInt(int64(i) + 1))
+}
+
+func TestAddressSet(t *testing.T) {
+	s := NewAddressSet(testAddress(0).Hex())
+	if !s.Contains(testAddress(0)) || s.Contains(testAddress(1)) {
+		t.Fatal("NewAddressSet")
+	}
+
+	// 超过布隆过滤器容量后自动重建，不能漏判
+	n := minBloomCapacity + 10
+	for i := 1; i < n; i++ {
+		s.Add(testAddress(i))
+	}
+	if s.bloom.Load().capacity < n {
+		t.Errorf("bloom capacity = %d, want >= %d", s.bloom.Load().capacity, n)
+	}
+	for i := 0; i < n; i++ {
+		if !s.Contains(testAddress(i)) {
+			t.Fatalf("address %d missing after Add", i)
+		}
+	}
+
+	s.Remove(testAddress(5))
+	if s.Contains(testAddress(5)) || !s.Contains(testAddress(6)) || s.Len() != n-1 {
+		t.Error("Remove")
+	}
+
+	s.Replace([]common.Address{testAddress(n), testAddress(n + 1)})
+	if s.Contains(testAddress(0)) || !s.Contains(testAddress(n)) || !s.Contains(testAddress(n+1)) || s.Len() != 2 {
+		t.Error("Replace")
+	}
+}
+
+// 并发读写（配合 go test -race）：已加入的地址在任意时刻都能查到
+func TestAddressSetConcurrent(t *testing.T) {
+	s := NewAddressSet()
+	const writers, perWriter = 4, minBloomCapacity / 2
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				addr := testAddress(w*perWriter + i)
+				s.Add(addr)
+				if !s.Contains(addr) {
+					t.Errorf("address %s missing right after Add", addr.Hex())
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			s.Contains(testAddress(i))
+			s.List()
+		}
+	}()
+	wg.Wait()
+
+	if s.Len() != writers*perWriter {
+		t.Errorf("Len = %d, want %d", s.Len(), writers*perWriter)
+	}
+}
+
+func TestSQLAddressLoader(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	query := `SELECT id, address FROM addresses WHERE chain_id = \$1 AND id > \$2 ORDER BY id`
+	mock.ExpectQuery(query).WithArgs(int64(1), int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "address"}).
+			AddRow(3, testAddress(0).Hex()).
+			AddRow(7, testAddress(1).Hex()))
+	// id 5 的事务晚于 id 7 提交，增量同步回看最近的 id 时补上
+	mock.ExpectQuery(query).WithArgs(int64(1), int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "address"}).
+			AddRow(3, testAddress(0).Hex()).
+			AddRow(5, testAddress(3).Hex()).
+			AddRow(7, testAddress(1).Hex()).
+			AddRow(9, testAddress(2).Hex()))
+	mock.ExpectQuery(query).WithArgs(int64(1), int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "address"}).
+			AddRow(3, testAddress(0).Hex()).
+			AddRow(5, testAddress(3).Hex()).
+			AddRow(7, testAddress(1).Hex()).
+			AddRow(9, testAddress(2).Hex()))
+
+	set := NewAddressSet(testAddress(99).Hex())
+	l := NewSQLAddressLoader(db, "postgres", 1)
+	if err := l.LoadAll(ctx, set); err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != 2 || set.Contains(testAddress(99)) {
+		t.Errorf("LoadAll 应替换整个集合, len = %d", set.Len())
+	}
+
+	for _, want := range []int{2, 0} {
+		n, err := l.LoadNew(ctx, set)
+		if err != nil || n != want {
+			t.Errorf("LoadNew = %d, %v, want %d", n, err, want)
+		}
+	}
+	if !set.Contains(testAddress(2)) || !set.Contains(testAddress(3)) || set.Len() != 4 {
+		t.Error("LoadNew 未加入新地址")
+	}
+
+	// 只回看最近 addressIDMargin 个 id
+	l.lastID = addressIDMargin + 9
+	mock.ExpectQuery(query).WithArgs(int64(1), int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "address"}))
+	if _, err := l.LoadNew(ctx, set); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,180 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Cursor 扫块游标：最后一个被所有处理器处理完成的区块
+type Cursor struct {
+	BlockNumber uint64      `json:"block_number"`
+	BlockHash   common.Hash `json:"block_hash"`
+}
+
+// CursorStore 游标存储接口
+// Load 在没有保存过游标时返回 (nil, nil)
+type CursorStore interface {
+	Load(ctx context.Context, chainID uint64) (*Cursor, error)
+	Save(ctx context.Context, chainID uint64, cursor Cursor) error
+}
+
+// FileCursorStore 基于文件的游标存储，每条链一个文件
+type FileCursorStore struct {
+	dir string
+}
+
+// NewFileCursorStore 创建文件游标存储
+func NewFileCursorStore(dir string) (*FileCursorStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cursor dir: %w", err)
+	}
+	return &FileCursorStore{dir: dir}, nil
+}
+
+// Load 读取游标
+func (s *FileCursorStore) Load(ctx context.Context, chainID uint64) (*Cursor, error) {
+	data, err := os.ReadFile(s.path(chainID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cursor: %w", err)
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("parse cursor: %w", err)
+	}
+	return &cursor, nil
+}
+
+// Save 保存游标（先写临时文件再 rename，避免进程崩溃时写坏文件）
+func (s *FileCursorStore) Save(ctx context.Context, chainID uint64, cursor Cursor) error {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return fmt.Errorf("encode cursor: %w", err)
+	}
+
+	tmp := s.path(chainID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write cursor: %w", err)
+	}
+	if err := os.Rename(tmp, s.path(chainID)); err != nil {
+		return fmt.Errorf("rename cursor: %w", err)
+	}
+	return nil
+}
+
+func (s *FileCursorStore) path(chainID uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("cursor_%d.json", chainID))
+}
+
+// SQLCursorStore 基于数据库的游标存储（表 scan_cursors）
+type SQLCursorStore struct {
+	db     *sql.DB
+	driver string // postgres, mysql, sqlite
+}
+
+// NewSQLCursorStore 创建数据库游标存储
+// 调用方负责导入对应的数据库驱动
+func NewSQLCursorStore(db *sql.DB, driver string) *SQLCursorStore {
+	return &SQLCursorStore{db: db, driver: driver}
+}
+
+// Init 创建游标表（已存在则跳过）
+func (s *SQLCursorStore) Init(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS scan_cursors (
+	chain_id     BIGINT PRIMARY KEY,
+	block_number BIGINT NOT NULL,
+	block_hash   VARCHAR(66) NOT NULL,
+	updated_at   TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("create scan_cursors: %w", err)
+	}
+	return nil
+}
+
+// Load 读取游标
+func (s *SQLCursorStore) Load(ctx context.Context, chainID uint64) (*Cursor, error) {
+	var (
+		number uint64
+		hash   string
+	)
+	err := s.db.QueryRowContext(ctx,
+		s.rebind("SELECT block_number, block_hash FROM scan_cursors WHERE chain_id = ?"),
+		chainID,
+	).Scan(&number, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query cursor: %w", err)
+	}
+
+	return &Cursor{BlockNumber: number, BlockHash: common.HexToHash(hash)}, nil
+}
+
+// Save 保存游标（事务内先查后写，兼容三种数据库）
+func (s *SQLCursorStore) Save(ctx context.Context, chainID uint64, cursor Cursor) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx,
+		s.rebind("SELECT COUNT(1) FROM scan_cursors WHERE chain_id = ?"),
+		chainID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("query cursor: %w", err)
+	}
+
+	query := "INSERT INTO scan_cursors (block_number, block_hash, updated_at, chain_id) VALUES (?, ?, ?, ?)"
+	if exists > 0 {
+		query = "UPDATE scan_cursors SET block_number = ?, block_hash = ?, updated_at = ? WHERE chain_id = ?"
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(query),
+		cursor.BlockNumber, cursor.BlockHash.Hex(), time.Now(), chainID,
+	); err != nil {
+		return fmt.Errorf("save cursor: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit cursor: %w", err)
+	}
+	return nil
+}
+
+// rebind 把 ? 占位符转换为 postgres 的 $n 格式
+func (s *SQLCursorStore) rebind(query string) string {
+	return rebind(s.driver, query)
+}
+
+func rebind(driver, query string) string {
+	if driver != "postgres" {
+		return query
+	}
+
+	out := make([]byte, 0, len(query)+8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			out = append(out, fmt.Sprintf("$%d", n)...)
+			continue
+		}
+		out = append(out, query[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+func TestFileCursorStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewFileCursorStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 首次运行没有游标
+	if cursor, err := s.Load(ctx, 1); err != nil || cursor != nil {
+		t.Fatalf("Load before save = %+v, %v, want nil", cursor, err)
+	}
+
+	want := Cursor{BlockNumber: 100, BlockHash: common.HexToHash("0x64")}
+	if err := s.Save(ctx, 1, want); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后读到同一个游标，其他链互不影响
+	s, err = NewFileCursorStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor, err := s.Load(ctx, 1); err != nil || cursor == nil || *cursor != want {
+		t.Errorf("Load = %+v, %v, want %+v", cursor, err, want)
+	}
+	if cursor, _ := s.Load(ctx, 2); cursor != nil {
+		t.Errorf("chain 2 cursor = %+v, want nil", cursor)
+	}
+}
+
+func TestSQLCursorStore(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewSQLCursorStore(db, "postgres")
+	hash := common.HexToHash("0x64")
+
+	// 首次运行没有游标
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT block_number, block_hash FROM scan_cursors WHERE chain_id = $1")).
+		WithArgs(uint64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"block_number", "block_hash"}))
+	if cursor, err := s.Load(ctx, 1); err != nil || cursor != nil {
+		t.Fatalf("Load before save = %+v, %v, want nil", cursor, err)
+	}
+
+	// 没有记录时插入，之后更新
+	for _, exists := range []int{0, 1} {
+		query := "INSERT INTO scan_cursors"
+		if exists > 0 {
+			query = "UPDATE scan_cursors SET"
+		}
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(1) FROM scan_cursors")).
+			WithArgs(uint64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(exists))
+		mock.ExpectExec(regexp.QuoteMeta(query)).
+			WithArgs(uint64(100), hash.Hex(), sqlmock.AnyArg(), uint64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		if err := s.Save(ctx, 1, Cursor{BlockNumber: 100, BlockHash: hash}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mock.ExpectQuery("SELECT block_number, block_hash FROM scan_cursors").
+		WillReturnRows(sqlmock.NewRows([]string{"block_number", "block_hash"}).AddRow(100, hash.Hex()))
+	cursor, err := s.Load(ctx, 1)
+	if err != nil || cursor == nil || cursor.BlockNumber != 100 || cursor.BlockHash != hash {
+		t.Errorf("Load = %+v, %v", cursor, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestScannerResume 有游标时从游标的下一个区块继续，没有时从配置的起始区块开始
+func TestScannerResume(t *testing.T) {
+	ctx := context.Background()
+	node := rpctest.NewServer()
+	defer node.Close()
+	pool, err := rpcpool.Dial(ctx, []string{node.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	cursors, err := NewFileCursorStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(Config{Client: pool, Cursor: cursors, StartBlock: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.startBlock != 50 {
+		t.Errorf("first run starts at %d, want 50", s.startBlock)
+	}
+
+	hash := common.HexToHash("0x64")
+	if err := cursors.Save(ctx, 1, Cursor{BlockNumber: 100, BlockHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+	s, err = New(Config{Client: pool, Cursor: cursors, StartBlock: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.startBlock != 101 {
+		t.Errorf("resumed at %d, want 101", s.startBlock)
+	}
+	if got, ok := s.window.get(100); !ok || got != hash {
+		t.Errorf("window = %s, %v, want cursor hash", got.Hex(), ok)
+	}
+}
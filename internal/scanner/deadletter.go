@@ -0,0 +1,225 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DeadLetter 处理失败、等待重放的区块或交易
+type DeadLetter struct {
+	ChainID     uint64      `json:"chain_id"`
+	Handler     string      `json:"handler"` // 处理器类型名，例如 DepositHandler
+	BlockNumber uint64      `json:"block_number"`
+	BlockHash   common.Hash `json:"block_hash"`
+	TxHash      common.Hash `json:"tx_hash"` // 区块级处理失败时为空
+	Error       string      `json:"error"`
+	Attempts    int         `json:"attempts"` // 重放失败次数
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// ID 死信唯一标识：同一处理器对同一区块/交易只保留一条
+func (d *DeadLetter) ID() string {
+	return fmt.Sprintf("%d:%d:%s:%s", d.ChainID, d.BlockNumber, d.TxHash.Hex(), d.Handler)
+}
+
+// DeadLetterStore 死信存储
+type DeadLetterStore interface {
+	Put(ctx context.Context, letter DeadLetter) error
+	List(ctx context.Context, chainID uint64) ([]DeadLetter, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// FileDeadLetterStore 文件死信存储（JSON 数组，每次变更整体重写）
+// 死信数量很少，整体重写足够简单可靠
+type FileDeadLetterStore struct {
+	path string
+
+	mu      sync.Mutex
+	letters map[string]DeadLetter
+}
+
+// NewFileDeadLetterStore 打开（或创建）死信文件
+func NewFileDeadLetterStore(path string) (*FileDeadLetterStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create dead letter dir: %w", err)
+	}
+
+	s := &FileDeadLetterStore{path: path, letters: make(map[string]DeadLetter)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read dead letters: %w", err)
+	}
+
+	var letters []DeadLetter
+	if err := json.Unmarshal(data, &letters); err != nil {
+		return nil, fmt.Errorf("parse dead letters: %w", err)
+	}
+	for _, letter := range letters {
+		s.letters[letter.ID()] = letter
+	}
+	return s, nil
+}
+
+// Put 写入死信（已存在则覆盖）
+func (s *FileDeadLetterStore) Put(ctx context.Context, letter DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.letters[letter.ID()] = letter
+	return s.flush()
+}
+
+// List 指定链的死信，按区块顺序
+func (s *FileDeadLetterStore) List(ctx context.Context, chainID uint64) ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var letters []DeadLetter
+	for _, letter := range s.letters {
+		if letter.ChainID == chainID {
+			letters = append(letters, letter)
+		}
+	}
+	sortDeadLetters(letters)
+	return letters, nil
+}
+
+// Delete 删除死信
+func (s *FileDeadLetterStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.letters[id]; !ok {
+		return nil
+	}
+	delete(s.letters, id)
+	return s.flush()
+}
+
+// flush 先写临时文件再 rename
+func (s *FileDeadLetterStore) flush() error {
+	letters := make([]DeadLetter, 0, len(s.letters))
+	for _, letter := range s.letters {
+		letters = append(letters, letter)
+	}
+	sortDeadLetters(letters)
+
+	data, err := json.MarshalIndent(letters, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode dead letters: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write dead letters: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("rename dead letters: %w", err)
+	}
+	return nil
+}
+
+func sortDeadLetters(letters []DeadLetter) {
+	sort.Slice(letters, func(i, j int) bool {
+		if letters[i].ChainID != letters[j].ChainID {
+			return letters[i].ChainID < letters[j].ChainID
+		}
+		if letters[i].BlockNumber != letters[j].BlockNumber {
+			return letters[i].BlockNumber < letters[j].BlockNumber
+		}
+		return letters[i].ID() < letters[j].ID()
+	})
+}
+
+// ReplayResult 重放统计
+type ReplayResult struct {
+	Replayed int // 重放成功并删除
+	Failed   int // 仍然失败，保留
+	Dropped  int // 区块已被重组掉，删除
+	Skipped  int // 没有对应的处理器，保留
+}
+
+// ReplayDeadLetters 重放本链的死信：重新获取区块/交易交给对应处理器
+// 成功的死信从存储中删除；所在区块已被重组掉的直接删除（重组后的新区块由正常扫块处理）
+func (s *Scanner) ReplayDeadLetters(ctx context.Context, store DeadLetterStore) (*ReplayResult, error) {
+	letters, err := store.List(ctx, s.chainID.Uint64())
+	if err != nil {
+		return nil, fmt.Errorf("list dead letters: %w", err)
+	}
+
+	handlers := make(map[string]Handler, len(s.handlers))
+	for _, h := range s.handlers {
+		handlers[HandlerName(h)] = h
+	}
+
+	result := &ReplayResult{}
+	for _, letter := range letters {
+		handler, ok := handlers[letter.Handler]
+		if !ok {
+			log.Printf("跳过死信 %s: 没有处理器 %s", letter.ID(), letter.Handler)
+			result.Skipped++
+			continue
+		}
+
+		block, err := s.client.BlockByNumber(ctx, new(big.Int).SetUint64(letter.BlockNumber))
+		if err != nil {
+			return result, fmt.Errorf("get block %d: %w", letter.BlockNumber, err)
+		}
+		if block.Hash() != letter.BlockHash {
+			log.Printf("死信 %s 所在区块已被重组，丢弃", letter.ID())
+			if err := store.Delete(ctx, letter.ID()); err != nil {
+				return result, err
+			}
+			result.Dropped++
+			continue
+		}
+
+		if err := s.replay(ctx, handler, block, letter.TxHash); err != nil {
+			log.Printf("重放死信 %s 失败: %v", letter.ID(), err)
+			letter.Error = err.Error()
+			letter.Attempts++
+			if err := store.Put(ctx, letter); err != nil {
+				return result, err
+			}
+			result.Failed++
+			continue
+		}
+
+		if err := store.Delete(ctx, letter.ID()); err != nil {
+			return result, err
+		}
+		result.Replayed++
+	}
+	return result, nil
+}
+
+// replay 对单个区块或交易重新调用处理器
+func (s *Scanner) replay(ctx context.Context, handler Handler, block *types.Block, txHash common.Hash) error {
+	if txHash == (common.Hash{}) {
+		return handler.HandleBlock(ctx, block)
+	}
+
+	tx := block.Transaction(txHash)
+	if tx == nil {
+		return fmt.Errorf("tx %s not in block %d", txHash.Hex(), block.NumberU64())
+	}
+	receipt, err := s.client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("get receipt %s: %w", txHash.Hex(), err)
+	}
+	return handler.HandleTransaction(ctx, tx, receipt)
+}
@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+// failingHandler 对指定区块的 HandleBlock 返回错误
+type failingHandler struct {
+	blockRecorder
+	fail map[uint64]bool
+}
+
+func (h *failingHandler) HandleBlock(ctx context.Context, block *types.Block) error {
+	if h.fail[block.NumberU64()] {
+		return errors.New("still down")
+	}
+	return h.blockRecorder.HandleBlock(ctx, block)
+}
+
+func TestFileDeadLetterStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dead_letters.json")
+	store, err := NewFileDeadLetterStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := DeadLetter{ChainID: 1, Handler: "DepositHandler", BlockNumber: 101, Error: "down"}
+	b := DeadLetter{ChainID: 1, Handler: "DepositHandler", BlockNumber: 100, TxHash: common.HexToHash("0x01")}
+	other := DeadLetter{ChainID: 56, Handler: "DepositHandler", BlockNumber: 100}
+	for _, letter := range []DeadLetter{a, b, other} {
+		if err := store.Put(ctx, letter); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 同一处理器、同一区块/交易只保留一条
+	a.Attempts = 1
+	if err := store.Put(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, b.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileDeadLetterStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	letters, err := reopened.List(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].ID() != a.ID() || letters[0].Attempts != 1 || letters[0].Error != "down" {
+		t.Fatalf("reloaded letters = %+v", letters)
+	}
+	if letters, _ := reopened.List(ctx, 56); len(letters) != 1 {
+		t.Fatalf("chain 56 letters = %+v", letters)
+	}
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	blocks := make(map[uint64]*types.Block)
+	for n := uint64(100); n <= 102; n++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(n), Difficulty: common.Big0}
+		blocks[n] = types.NewBlock(header, nil, nil, trie.NewStackTrie(nil))
+	}
+	node := rpctest.NewServer()
+	defer node.Close()
+	node.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var number hexutil.Uint64
+		if err := json.Unmarshal(params[0], &number); err != nil {
+			return nil, err
+		}
+		return rpctest.Block(blocks[uint64(number)]), nil
+	})
+	pool, err := rpcpool.Dial(ctx, []string{node.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	s, err := New(Config{Client: pool, StartBlock: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := &failingHandler{fail: map[uint64]bool{101: true}}
+	s.AddHandler(handler)
+
+	path := filepath.Join(t.TempDir(), "dead_letters.json")
+	store, err := NewFileDeadLetterStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	letters := []DeadLetter{
+		{ChainID: 1, Handler: "failingHandler", BlockNumber: 100, BlockHash: blocks[100].Hash()},       // 重放成功
+		{ChainID: 1, Handler: "failingHandler", BlockNumber: 101, BlockHash: blocks[101].Hash()},       // 仍然失败
+		{ChainID: 1, Handler: "failingHandler", BlockNumber: 102, BlockHash: common.HexToHash("0x01")}, // 已被重组
+		{ChainID: 1, Handler: "DepositHandler", BlockNumber: 100, BlockHash: blocks[100].Hash()},       // 没有处理器
+	}
+	for _, letter := range letters {
+		if err := store.Put(ctx, letter); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := s.ReplayDeadLetters(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *result != (ReplayResult{Replayed: 1, Failed: 1, Dropped: 1, Skipped: 1}) {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(handler.blocks) != 1 || handler.blocks[0] != 100 {
+		t.Errorf("handled blocks = %v, want [100]", handler.blocks)
+	}
+
+	// 成功和被重组的删除，失败的保留并记录重放次数，结果已写入文件
+	reopened, err := NewFileDeadLetterStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, _ := reopened.List(ctx, 1)
+	if len(left) != 2 {
+		t.Fatalf("left = %+v, want 2 letters", left)
+	}
+	if left[0].ID() != letters[3].ID() || left[1].ID() != letters[1].ID() {
+		t.Fatalf("left = %+v", left)
+	}
+	if left[1].Attempts != 1 || left[1].Error != "still down" {
+		t.Errorf("failed letter = %+v, want attempts 1 and the replay error", left[1])
+	}
+}
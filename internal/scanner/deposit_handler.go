@@ -11,36 +11,63 @@ import (
 
 // DepositHandler 充值处理器
 type DepositHandler struct {
-	watchAddresses map[common.Address]bool // 监控的地址
-	callback       func(deposit *Deposit)   // 充值回调
+	watch      *AddressSet                                       // 监控的地址（可与其他处理器共享）
+	callback   func(ctx context.Context, deposit *Deposit) error // 充值回调（只有成功的交易）
+	onFailed   func(deposit *Deposit)                            // 失败充值回调（可选，不入账）
+	onRollback func(fromBlock uint64)                            // 链重组回调（可选）
 }
 
 // Deposit 充值信息
 type Deposit struct {
+	ChainID     uint64 // 由 DepositTracker 填充
 	TxHash      common.Hash
 	BlockNumber uint64
 	From        common.Address
 	To          common.Address
 	Value       *big.Int
 	Status      uint64 // 1=成功, 0=失败
+
+	// 代币充值字段（原生币充值时 TokenAddress 为零地址）
+	TokenAddress  common.Address
+	TokenDecimals uint8
+	LogIndex      uint // Transfer 事件在区块中的日志序号
+
+	// 内部转账字段：调用树中的深度优先序号（0 为顶层交易本身）
+	TraceIndex uint
+
+	// 确认状态（对应 deposits 表的 status / confirm_count），由 DepositTracker 维护
+	State        DepositState
+	ConfirmCount uint64
 }
 
-// NewDepositHandler 创建充值处理器
-func NewDepositHandler(addresses []string, callback func(*Deposit)) *DepositHandler {
-	watchMap := make(map[common.Address]bool)
-	for _, addr := range addresses {
-		watchMap[common.HexToAddress(addr)] = true
-	}
+// IsToken 是否为代币充值
+func (d *Deposit) IsToken() bool {
+	return d.TokenAddress != (common.Address{})
+}
 
+// NewDepositHandler 创建充值处理器
+// callback 返回错误（例如充值保存失败）时 HandleTransaction 返回该错误，由扫块器按错误策略处理
+func NewDepositHandler(watch *AddressSet, callback func(context.Context, *Deposit) error) *DepositHandler {
 	return &DepositHandler{
-		watchAddresses: watchMap,
-		callback:       callback,
+		watch:    watch,
+		callback: callback,
 	}
 }
 
-// AddWatchAddress 添加监控地址
+// AddWatchAddress 添加监控地址（并发安全，可在扫块过程中调用）
 func (h *DepositHandler) AddWatchAddress(addr string) {
-	h.watchAddresses[common.HexToAddress(addr)] = true
+	h.watch.Add(common.HexToAddress(addr))
+}
+
+// SetRollbackCallback 设置链重组回调，>= fromBlock 的充值需要冲正
+func (h *DepositHandler) SetRollbackCallback(fn func(fromBlock uint64)) {
+	h.onRollback = fn
+}
+
+// SetFailedCallback 设置失败充值回调
+// 失败（revert）的交易不会入账，但客服需要能查到用户的充值尝试
+func (h *DepositHandler) SetFailedCallback(fn func(deposit *Deposit)) {
+	h.onFailed = fn
 }
 
 // HandleBlock 处理区块
@@ -49,6 +76,11 @@ func (h *DepositHandler) HandleBlock(ctx context.Context, block *types.Block) er
 	return nil
 }
 
+// WantsReceipt 只关心发往监控地址且带 value 的交易
+func (h *DepositHandler) WantsReceipt(tx *types.Transaction) bool {
+	return tx.To() != nil && tx.Value().Sign() > 0 && h.watch.Contains(*tx.To())
+}
+
 // HandleTransaction 处理交易
 func (h *DepositHandler) HandleTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
 	// 只处理监控地址的交易
@@ -56,7 +88,7 @@ func (h *DepositHandler) HandleTransaction(ctx context.Context, tx *types.Transa
 		return nil // 合约创建交易
 	}
 
-	if !h.watchAddresses[*tx.To()] {
+	if !h.watch.Contains(*tx.To()) {
 		return nil // 不是我们监控的地址
 	}
 
@@ -81,6 +113,20 @@ func (h *DepositHandler) HandleTransaction(ctx context.Context, tx *types.Transa
 		Status:      receipt.Status,
 	}
 
+	// 失败的交易没有转移资金，不能入账
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		log.Printf("检测到失败的充值交易: from=%s, to=%s, value=%s ETH, tx=%s",
+			from.Hex(),
+			tx.To().Hex(),
+			weiToEth(tx.Value()),
+			tx.Hash().Hex(),
+		)
+		if h.onFailed != nil {
+			h.onFailed(deposit)
+		}
+		return nil
+	}
+
 	log.Printf("检测到充值: from=%s, to=%s, value=%s ETH, tx=%s",
 		from.Hex(),
 		tx.To().Hex(),
@@ -90,12 +136,23 @@ func (h *DepositHandler) HandleTransaction(ctx context.Context, tx *types.Transa
 
 	// 调用回调
 	if h.callback != nil {
-		h.callback(deposit)
+		if err := h.callback(ctx, deposit); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// HandleRollback 处理链重组
+func (h *DepositHandler) HandleRollback(ctx context.Context, fromBlock uint64) error {
+	log.Printf("链重组: 区块 %d 及之后的充值失效", fromBlock)
+	if h.onRollback != nil {
+		h.onRollback(fromBlock)
+	}
+	return nil
+}
+
 // weiToEth 工具函数
 func weiToEth(wei *big.Int) string {
 	eth := new(big.Float).Quo(
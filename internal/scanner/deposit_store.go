@@ -0,0 +1,390 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DepositKey 充值幂等键：同一链上同一交易内的同一笔转账
+type DepositKey struct {
+	ChainID uint64
+	TxHash  common.Hash
+	Index   string // 代币为 "log:<日志序号>"，原生币为 "trace:<调用序号>"
+}
+
+// Key 充值的幂等键
+func (d *Deposit) Key() DepositKey {
+	index := fmt.Sprintf("trace:%d", d.TraceIndex)
+	if d.IsToken() {
+		index = fmt.Sprintf("log:%d", d.LogIndex)
+	}
+	return DepositKey{ChainID: d.ChainID, TxHash: d.TxHash, Index: index}
+}
+
+// DepositStore 充值存储，保证重复扫描不会重复通知
+type DepositStore interface {
+	// Save 按幂等键写入充值；只有状态真正前进时返回 true，调用方据此决定是否通知
+	Save(ctx context.Context, deposit *Deposit) (bool, error)
+	// Pending 未完成确认的充值，重启后用于恢复跟踪
+	Pending(ctx context.Context, chainID uint64) ([]*Deposit, error)
+}
+
+// advances 判断新状态相对已保存状态是否前进
+func advances(saved, next *Deposit) bool {
+	switch saved.State {
+	case DepositConfirmed:
+		return false // 已入账的充值不再变化
+	case DepositOrphaned:
+		return next.State != DepositOrphaned // 重组后被重新打包
+	}
+
+	// pending
+	switch next.State {
+	case DepositPending:
+		return next.ConfirmCount > saved.ConfirmCount || next.BlockNumber != saved.BlockNumber
+	default:
+		return true
+	}
+}
+
+// MemoryDepositStore 内存充值存储（进程重启后丢失）
+// 已确认 / 已孤立的充值只保留幂等键和状态，避免长期运行时内存持续增长
+type MemoryDepositStore struct {
+	mu       sync.Mutex
+	deposits map[DepositKey]*Deposit
+}
+
+// NewMemoryDepositStore 创建内存充值存储
+func NewMemoryDepositStore() *MemoryDepositStore {
+	return &MemoryDepositStore{deposits: make(map[DepositKey]*Deposit)}
+}
+
+// Save 写入充值
+func (s *MemoryDepositStore) Save(ctx context.Context, deposit *Deposit) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(deposit), nil
+}
+
+func (s *MemoryDepositStore) save(deposit *Deposit) bool {
+	key := deposit.Key()
+	if saved, ok := s.deposits[key]; ok && !advances(saved, deposit) {
+		return false
+	}
+
+	s.put(deposit)
+	return true
+}
+
+// put 保存快照，已完成的充值只保留幂等判断需要的字段
+func (s *MemoryDepositStore) put(deposit *Deposit) {
+	snapshot := *deposit
+	if deposit.State != DepositPending {
+		snapshot = Deposit{
+			ChainID:      deposit.ChainID,
+			TxHash:       deposit.TxHash,
+			TokenAddress: deposit.TokenAddress,
+			LogIndex:     deposit.LogIndex,
+			TraceIndex:   deposit.TraceIndex,
+			State:        deposit.State,
+		}
+	}
+	s.deposits[deposit.Key()] = &snapshot
+}
+
+// Pending 未完成确认的充值
+func (s *MemoryDepositStore) Pending(ctx context.Context, chainID uint64) ([]*Deposit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []*Deposit
+	for key, deposit := range s.deposits {
+		if key.ChainID == chainID && deposit.State == DepositPending {
+			snapshot := *deposit
+			pending = append(pending, &snapshot)
+		}
+	}
+	return pending, nil
+}
+
+// FileDepositStore 文件充值存储：追加写 JSON Lines，启动时回放到内存
+// 每次确认数变化都会追加一行，启动时和追加行数超过记录数两倍时重写文件。
+// 适合开发和单机部署，生产环境建议使用 SQLDepositStore
+type FileDepositStore struct {
+	*MemoryDepositStore
+	path  string
+	file  *os.File
+	lines int // 文件当前行数
+}
+
+// compactMinLines 行数少于该值时不压缩
+const compactMinLines = 1024
+
+// NewFileDepositStore 打开（或创建）充值存储文件
+func NewFileDepositStore(path string) (*FileDepositStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create deposit dir: %w", err)
+	}
+
+	mem := NewMemoryDepositStore()
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var deposit Deposit
+			if err := json.Unmarshal(scanner.Bytes(), &deposit); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("parse deposit: %w", err)
+			}
+			mem.put(&deposit)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read deposits: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("open deposits: %w", err)
+	}
+
+	s := &FileDepositStore{MemoryDepositStore: mem, path: path}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save 写入充值，状态前进时追加一行
+func (s *FileDepositStore) Save(ctx context.Context, deposit *Deposit) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := deposit.Key()
+	if saved, ok := s.deposits[key]; ok && !advances(saved, deposit) {
+		return false, nil
+	}
+
+	data, err := json.Marshal(deposit)
+	if err != nil {
+		return false, fmt.Errorf("encode deposit: %w", err)
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return false, fmt.Errorf("write deposit: %w", err)
+	}
+
+	s.lines++
+	s.save(deposit)
+
+	if s.lines >= compactMinLines && s.lines > 2*len(s.deposits) {
+		if err := s.compact(); err != nil {
+			log.Printf("压缩充值文件失败: %v", err)
+		}
+	}
+	return true, nil
+}
+
+// compact 每个充值只写一行当前状态，写入临时文件后替换原文件
+func (s *FileDepositStore) compact() error {
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create deposits: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, deposit := range s.deposits {
+		data, err := json.Marshal(deposit)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("encode deposit: %w", err)
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write deposits: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync deposits: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close deposits: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replace deposits: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open deposits: %w", err)
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = file
+	s.lines = len(s.deposits)
+	return nil
+}
+
+// Close 关闭文件
+func (s *FileDepositStore) Close() error {
+	return s.file.Close()
+}
+
+// SQLDepositStore 基于数据库的充值存储（表 deposits）
+type SQLDepositStore struct {
+	db     *sql.DB
+	driver string // postgres, mysql, sqlite
+}
+
+// NewSQLDepositStore 创建数据库充值存储
+// 调用方负责导入对应的数据库驱动
+func NewSQLDepositStore(db *sql.DB, driver string) *SQLDepositStore {
+	return &SQLDepositStore{db: db, driver: driver}
+}
+
+// Init 创建充值表（已存在则跳过）
+func (s *SQLDepositStore) Init(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS deposits (
+	chain_id       BIGINT NOT NULL,
+	tx_hash        VARCHAR(66) NOT NULL,
+	event_index    VARCHAR(32) NOT NULL,
+	block_number   BIGINT NOT NULL,
+	from_address   VARCHAR(42) NOT NULL,
+	address        VARCHAR(42) NOT NULL,
+	token_address  VARCHAR(42) NOT NULL,
+	token_decimals SMALLINT NOT NULL,
+	amount         VARCHAR(78) NOT NULL,
+	confirm_count  BIGINT NOT NULL,
+	status         VARCHAR(16) NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	updated_at     TIMESTAMP NOT NULL,
+	PRIMARY KEY (chain_id, tx_hash, event_index)
+)`)
+	if err != nil {
+		return fmt.Errorf("create deposits: %w", err)
+	}
+	return nil
+}
+
+// Save 写入充值
+// 先按主键冲突忽略插入，已存在时按读到的旧状态做条件更新（乐观锁），
+// 多个进程同时写同一笔充值时只有一方的状态前进生效
+func (s *SQLDepositStore) Save(ctx context.Context, deposit *Deposit) (bool, error) {
+	key := deposit.Key()
+	now := time.Now()
+
+	res, err := s.db.ExecContext(ctx, s.insertIgnore(`INSERT INTO deposits
+	(chain_id, tx_hash, event_index, block_number, from_address, address, token_address,
+	 token_decimals, amount, confirm_count, status, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		key.ChainID, key.TxHash.Hex(), key.Index, deposit.BlockNumber,
+		deposit.From.Hex(), deposit.To.Hex(), deposit.TokenAddress.Hex(),
+		deposit.TokenDecimals, deposit.Value.String(), deposit.ConfirmCount,
+		string(deposit.State), now, now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("insert deposit: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return false, fmt.Errorf("insert deposit: %w", err)
+	} else if n > 0 {
+		return true, nil
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		saved := Deposit{}
+		var state string
+		err := s.db.QueryRowContext(ctx,
+			rebind(s.driver, "SELECT status, confirm_count, block_number FROM deposits WHERE chain_id = ? AND tx_hash = ? AND event_index = ?"),
+			key.ChainID, key.TxHash.Hex(), key.Index,
+		).Scan(&state, &saved.ConfirmCount, &saved.BlockNumber)
+		if err != nil {
+			return false, fmt.Errorf("query deposit: %w", err)
+		}
+
+		saved.State = DepositState(state)
+		if !advances(&saved, deposit) {
+			return false, nil
+		}
+
+		res, err := s.db.ExecContext(ctx,
+			rebind(s.driver, `UPDATE deposits SET block_number = ?, confirm_count = ?, status = ?, updated_at = ?
+	WHERE chain_id = ? AND tx_hash = ? AND event_index = ? AND status = ? AND confirm_count = ? AND block_number = ?`),
+			deposit.BlockNumber, deposit.ConfirmCount, string(deposit.State), now,
+			key.ChainID, key.TxHash.Hex(), key.Index, state, saved.ConfirmCount, saved.BlockNumber,
+		)
+		if err != nil {
+			return false, fmt.Errorf("update deposit: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return false, fmt.Errorf("update deposit: %w", err)
+		}
+		if n > 0 {
+			return true, nil
+		}
+		// 读到的状态已被其他进程修改，重新比较
+	}
+	return false, fmt.Errorf("update deposit %s: concurrent modification", key.TxHash.Hex())
+}
+
+// insertIgnore 主键冲突时不报错也不修改（受影响行数为 0）
+func (s *SQLDepositStore) insertIgnore(query string) string {
+	switch s.driver {
+	case "mysql":
+		return query + " ON DUPLICATE KEY UPDATE chain_id = chain_id"
+	default: // postgres, sqlite
+		return rebind(s.driver, query+" ON CONFLICT (chain_id, tx_hash, event_index) DO NOTHING")
+	}
+}
+
+// Pending 未完成确认的充值
+func (s *SQLDepositStore) Pending(ctx context.Context, chainID uint64) ([]*Deposit, error) {
+	rows, err := s.db.QueryContext(ctx, rebind(s.driver, `SELECT
+	tx_hash, event_index, block_number, from_address, address, token_address,
+	token_decimals, amount, confirm_count
+	FROM deposits WHERE chain_id = ? AND status = ?`),
+		chainID, string(DepositPending),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query pending deposits: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []*Deposit
+	for rows.Next() {
+		var (
+			txHash, index, from, to, token, amount string
+			// 只有成功的交易会进入确认跟踪（失败交易走 onFailed，不写入）
+			deposit = Deposit{ChainID: chainID, State: DepositPending, Status: types.ReceiptStatusSuccessful}
+		)
+		if err := rows.Scan(&txHash, &index, &deposit.BlockNumber, &from, &to, &token,
+			&deposit.TokenDecimals, &amount, &deposit.ConfirmCount); err != nil {
+			return nil, fmt.Errorf("scan deposit: %w", err)
+		}
+
+		deposit.TxHash = common.HexToHash(txHash)
+		deposit.From = common.HexToAddress(from)
+		deposit.To = common.HexToAddress(to)
+		deposit.TokenAddress = common.HexToAddress(token)
+		deposit.Value, _ = new(big.Int).SetString(amount, 10)
+		if _, err := fmt.Sscanf(index, "log:%d", &deposit.LogIndex); err != nil {
+			fmt.Sscanf(index, "trace:%d", &deposit.TraceIndex)
+		}
+		pending = append(pending, &deposit)
+	}
+	return pending, rows.Err()
+}
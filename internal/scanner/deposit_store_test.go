@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestFileDepositStoreCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "deposits.jsonl")
+
+	s, err := NewFileDepositStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Deposit{ChainID: 1, TxHash: common.HexToHash("0x01"), BlockNumber: 100, Value: big.NewInt(1), Status: 1}
+	b := &Deposit{ChainID: 1, TxHash: common.HexToHash("0x02"), BlockNumber: 100, Value: big.NewInt(2), Status: 1}
+	for count := uint64(0); count < 3; count++ {
+		a.State, a.ConfirmCount = DepositPending, count
+		s.Save(ctx, a)
+	}
+	a.State = DepositConfirmed
+	s.Save(ctx, a)
+	b.State = DepositPending
+	s.Save(ctx, b)
+	s.Close()
+
+	// 重新打开后每笔充值只剩一行，已确认的不再出现在 Pending 中
+	s, err = NewFileDepositStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	data, _ := os.ReadFile(path)
+	if lines := bytes.Count(data, []byte("\n")); lines != 2 {
+		t.Errorf("compacted lines = %d, want 2", lines)
+	}
+	pending, _ := s.Pending(ctx, 1)
+	if len(pending) != 1 || pending[0].TxHash != b.TxHash || pending[0].Value.Int64() != 2 {
+		t.Errorf("pending = %+v", pending)
+	}
+
+	// 已确认的充值被重扫到时不会重复通知
+	a.State, a.ConfirmCount = DepositPending, 1
+	if ok, _ := s.Save(ctx, a); ok {
+		t.Error("已确认的充值不应回退为 pending")
+	}
+}
+
+func TestSQLDepositStore(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewSQLDepositStore(db, "postgres")
+
+	d := &Deposit{
+		ChainID: 1, TxHash: common.HexToHash("0x01"), BlockNumber: 100,
+		Value: big.NewInt(5), Status: 1, State: DepositPending, ConfirmCount: 2,
+	}
+
+	// 已存在：冲突忽略后按旧状态条件更新
+	mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (chain_id, tx_hash, event_index) DO NOTHING")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT status, confirm_count, block_number FROM deposits").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "confirm_count", "block_number"}).AddRow("pending", 1, 100))
+	mock.ExpectExec("UPDATE deposits SET").
+		WithArgs(uint64(100), uint64(2), "pending", sqlmock.AnyArg(), uint64(1), d.TxHash.Hex(), "trace:0", "pending", uint64(1), uint64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if ok, err := s.Save(ctx, d); err != nil || !ok {
+		t.Fatalf("Save = %v, %v", ok, err)
+	}
+
+	// 其他进程已写入更高的确认数，不再前进
+	mock.ExpectExec("INSERT INTO deposits").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT status").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "confirm_count", "block_number"}).AddRow("pending", 3, 100))
+	if ok, err := s.Save(ctx, d); err != nil || ok {
+		t.Fatalf("Save = %v, %v, want false", ok, err)
+	}
+
+	mock.ExpectQuery("SELECT").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_hash", "event_index", "block_number", "from_address", "address",
+			"token_address", "token_decimals", "amount", "confirm_count"}).
+			AddRow(d.TxHash.Hex(), "log:4", 100, common.Address{}.Hex(), common.Address{}.Hex(),
+				common.HexToAddress("0xdac17f958d2ee523a2206206994597c13d831ec7").Hex(), 6, "5", 2))
+	pending, err := s.Pending(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Status != types.ReceiptStatusSuccessful || pending[0].LogIndex != 4 || pending[0].Value.Int64() != 5 {
+		t.Errorf("pending = %+v", pending)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
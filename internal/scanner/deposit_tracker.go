@@ -0,0 +1,201 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DepositState 充值状态
+type DepositState string
+
+const (
+	DepositPending   DepositState = "pending"   // 已上链，等待确认
+	DepositConfirmed DepositState = "confirmed" // 达到确认数，可以入账
+	DepositOrphaned  DepositState = "orphaned"  // 所在区块被重组掉
+)
+
+// DepositTracker 充值确认跟踪器
+//
+// 充值处理器把 Track 作为回调，在链头检测到充值后立即以 pending 状态通知，
+// 之后每个新区块更新一次 confirm_count，达到确认数后通知 confirmed；
+// 确认前发生链重组则通知 orphaned。
+// 每次状态变化先写入 DepositStore，只有真正前进的状态才会回调，
+// 因此重复扫描同一区块范围或重启后重扫不会重复通知。
+// 保存失败时返回错误，由扫块器按处理器的错误策略重试或停止；
+// 同时保留在跟踪列表中，下个区块再次保存。
+// 跟踪器本身也要作为 Handler 加到扫块器上（放在充值处理器之后）。
+type DepositTracker struct {
+	chainID       uint64
+	confirmations uint64
+	store         DepositStore
+	callback      func(deposit *Deposit)
+
+	mu      sync.Mutex
+	head    uint64                  // 最新处理的区块
+	lag     uint64                  // 扫块位置落后链头的区块数，见 SetScanLag
+	pending map[DepositKey]*Deposit // 等待确认的充值
+}
+
+// NewDepositTracker 创建确认跟踪器
+func NewDepositTracker(chainID, confirmations uint64, store DepositStore, callback func(*Deposit)) *DepositTracker {
+	if store == nil {
+		store = NewMemoryDepositStore()
+	}
+	return &DepositTracker{
+		chainID:       chainID,
+		confirmations: confirmations,
+		store:         store,
+		callback:      callback,
+		pending:       make(map[DepositKey]*Deposit),
+	}
+}
+
+// SetScanLag 扫块器只处理落后链头 lag 个区块的已确认区块时设置（日志模式）
+// 确认数按 链头 = 已处理区块 + lag 计算，不再重复等待 lag 个区块
+func (t *DepositTracker) SetScanLag(lag uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lag = lag
+}
+
+// Restore 从存储中恢复未完成确认的充值（启动扫块前调用）
+func (t *DepositTracker) Restore(ctx context.Context) error {
+	deposits, err := t.store.Pending(ctx, t.chainID)
+	if err != nil {
+		return fmt.Errorf("load pending deposits: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, deposit := range deposits {
+		t.pending[deposit.Key()] = deposit
+	}
+	log.Printf("恢复待确认充值: chainID=%d, count=%d", t.chainID, len(deposits))
+	return nil
+}
+
+// Track 开始跟踪新检测到的充值
+func (t *DepositTracker) Track(ctx context.Context, deposit *Deposit) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	deposit.ChainID = t.chainID
+	deposit.ConfirmCount = t.confirmCount(deposit.BlockNumber)
+	deposit.State = DepositPending
+	if deposit.ConfirmCount >= t.confirmations {
+		// 历史区块或不需要等待确认
+		deposit.State = DepositConfirmed
+	}
+	err := t.emit(ctx, deposit)
+	if err != nil || deposit.State == DepositPending {
+		t.pending[deposit.Key()] = deposit
+	}
+	return err
+}
+
+// HandleBlock 新区块到达，更新所有待确认充值
+func (t *DepositTracker) HandleBlock(ctx context.Context, block *types.Block) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.head = block.NumberU64()
+	var errs []error
+	for key, deposit := range t.pending {
+		if deposit.State != DepositPending {
+			// 上次保存失败（重组后的 orphaned，或检测到时已确认），重试
+			if err := t.emit(ctx, deposit); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			delete(t.pending, key)
+			continue
+		}
+
+		count := t.confirmCount(deposit.BlockNumber)
+		if count <= deposit.ConfirmCount {
+			continue
+		}
+
+		deposit.ConfirmCount = count
+		if count >= t.confirmations {
+			deposit.State = DepositConfirmed
+		}
+		if err := t.emit(ctx, deposit); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if deposit.State == DepositConfirmed {
+			delete(t.pending, key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WantsReceipt 跟踪器不处理交易
+func (t *DepositTracker) WantsReceipt(tx *types.Transaction) bool {
+	return false
+}
+
+// HandleTransaction 跟踪器不处理交易
+func (t *DepositTracker) HandleTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	return nil
+}
+
+// HandleRollback 链重组，>= fromBlock 的待确认充值变为 orphaned
+func (t *DepositTracker) HandleRollback(ctx context.Context, fromBlock uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
+	for key, deposit := range t.pending {
+		if deposit.BlockNumber < fromBlock {
+			continue
+		}
+		deposit.State = DepositOrphaned
+		if err := t.emit(ctx, deposit); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(t.pending, key)
+	}
+
+	// 已确认的充值不会再跟踪，重组深度超过确认数时需要人工处理
+	if t.confirmations > 0 && fromBlock+t.confirmations <= t.head+1 {
+		log.Printf("⚠️ 链重组深度超过确认数: fromBlock=%d, head=%d, 已确认的充值可能失效", fromBlock, t.head)
+	}
+
+	if fromBlock > 0 {
+		t.head = fromBlock - 1
+	}
+	return errors.Join(errs...)
+}
+
+// confirmCount 区块的确认数（所在区块本身算 1 个确认）
+func (t *DepositTracker) confirmCount(blockNumber uint64) uint64 {
+	head := t.head + t.lag
+	if head < blockNumber {
+		return 1
+	}
+	return head - blockNumber + 1
+}
+
+// emit 持久化状态，状态前进时回调一份快照
+// 返回错误表示存储失败，调用方保留跟踪以便下个区块重试
+func (t *DepositTracker) emit(ctx context.Context, deposit *Deposit) error {
+	advanced, err := t.store.Save(ctx, deposit)
+	if err != nil {
+		return fmt.Errorf("save deposit %s: %w", deposit.TxHash.Hex(), err)
+	}
+	if !advanced || t.callback == nil {
+		return nil
+	}
+
+	snapshot := *deposit
+	t.callback(&snapshot)
+	return nil
+}
@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDepositTrackerLifecycle(t *testing.T) {
+	ctx := context.Background()
+
+	var events []Deposit
+	tracker := NewDepositTracker(1, 3, nil, func(d *Deposit) { events = append(events, *d) })
+
+	newBlock := func(n int64) *types.Block {
+		return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(n)})
+	}
+
+	tracker.HandleBlock(ctx, newBlock(100))
+	tracker.Track(ctx, &Deposit{TxHash: common.HexToHash("0x01"), BlockNumber: 100, Value: big.NewInt(1)})
+	tracker.HandleBlock(ctx, newBlock(101))
+	tracker.HandleBlock(ctx, newBlock(102))
+	tracker.Track(ctx, &Deposit{TxHash: common.HexToHash("0x02"), BlockNumber: 102, Value: big.NewInt(2)})
+	tracker.HandleRollback(ctx, 102)
+	tracker.HandleBlock(ctx, newBlock(103))
+
+	// 重扫同一区块不会重复通知
+	tracker.HandleRollback(ctx, 100)
+	tracker.HandleBlock(ctx, newBlock(100))
+	tracker.Track(ctx, &Deposit{TxHash: common.HexToHash("0x01"), BlockNumber: 100, Value: big.NewInt(1)})
+	tracker.HandleBlock(ctx, newBlock(101))
+	tracker.HandleBlock(ctx, newBlock(102))
+
+	want := []struct {
+		tx    string
+		state DepositState
+		count uint64
+	}{
+		{"0x01", DepositPending, 1},
+		{"0x01", DepositPending, 2},
+		{"0x01", DepositConfirmed, 3},
+		{"0x02", DepositPending, 1},
+		{"0x02", DepositOrphaned, 1},
+	}
+
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.TxHash != common.HexToHash(w.tx) || e.State != w.state || e.ConfirmCount != w.count {
+			t.Errorf("event %d = (%s, %s, %d), want (%s, %s, %d)",
+				i, e.TxHash.Hex(), e.State, e.ConfirmCount,
+				common.HexToHash(w.tx).Hex(), w.state, w.count)
+		}
+	}
+}
+
+// failingDepositStore 前 fail 次保存失败
+type failingDepositStore struct {
+	*MemoryDepositStore
+	fail int
+}
+
+func (s *failingDepositStore) Save(ctx context.Context, deposit *Deposit) (bool, error) {
+	if s.fail > 0 {
+		s.fail--
+		return false, errors.New("database is down")
+	}
+	return s.MemoryDepositStore.Save(ctx, deposit)
+}
+
+func TestDepositTrackerSaveFailure(t *testing.T) {
+	ctx := context.Background()
+	store := &failingDepositStore{MemoryDepositStore: NewMemoryDepositStore(), fail: 1}
+	var events []Deposit
+	tracker := NewDepositTracker(1, 3, store, func(d *Deposit) { events = append(events, *d) })
+
+	// 检测到时已经确认（补扫历史区块），保存失败要返回错误，不能悄悄丢掉
+	tracker.HandleBlock(ctx, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(110)}))
+	if err := tracker.Track(ctx, &Deposit{TxHash: common.HexToHash("0x01"), BlockNumber: 100, Value: big.NewInt(1)}); err == nil {
+		t.Fatal("Track should return the save error")
+	}
+	if len(events) != 0 {
+		t.Fatalf("notified an unsaved deposit: %+v", events)
+	}
+
+	// 下个区块重试保存
+	if err := tracker.HandleBlock(ctx, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(111)})); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].State != DepositConfirmed {
+		t.Fatalf("events = %+v, want one confirmed deposit", events)
+	}
+}
+
+func TestDepositTrackerScanLag(t *testing.T) {
+	ctx := context.Background()
+	var events []Deposit
+	tracker := NewDepositTracker(1, 3, nil, func(d *Deposit) { events = append(events, *d) })
+	// 日志模式扫到 链头 - 3，处理区块 100 时链头已是 103
+	tracker.SetScanLag(3)
+
+	tracker.HandleBlock(ctx, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100)}))
+	if err := tracker.Track(ctx, &Deposit{TxHash: common.HexToHash("0x01"), BlockNumber: 100, Value: big.NewInt(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].State != DepositConfirmed || events[0].ConfirmCount != 4 {
+		t.Fatalf("events = %+v, want confirmed with 4 confirmations", events)
+	}
+}
@@ -0,0 +1,237 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ScanMode 扫块模式
+type ScanMode string
+
+const (
+	ModeBlocks ScanMode = "blocks" // 逐块获取完整区块和收据（默认）
+	ModeLogs   ScanMode = "logs"   // 用 eth_getLogs 只取 Transfer 日志，适合只收代币的链
+)
+
+const (
+	defaultLogRange   = 2000 // 单次 eth_getLogs 的默认区块跨度
+	maxTopicAddresses = 1000 // 单次查询 topic 中最多放的收款地址数，超过时分批查询
+)
+
+// LogFilter 日志模式的过滤条件
+type LogFilter struct {
+	Contracts []common.Address // 代币合约（为空表示不限合约）
+	Watch     *AddressSet      // 收款地址，作为 Transfer 的 to topic 过滤
+}
+
+// scanLogs 日志模式扫描 [from, to]
+//
+// 按 Transfer topic + 收款地址 topic 查询 eth_getLogs，只获取命中日志的区块和收据，
+// 再按区块顺序交给处理器；每段区间的最后一个区块会以区块头的形式调用 HandleBlock，
+// 确认跟踪器据此推进链头。节点拒绝过大的查询时区间减半重试，成功后逐步放大。
+// 日志模式不检测重组，只应扫描已确认的区块（Config.ConfirmBlocks）。
+// 返回处理完的最后一个区块
+func (s *Scanner) scanLogs(ctx context.Context, from, to uint64) (uint64, error) {
+	done := from - 1
+	for from <= to {
+		end := from + s.logRange - 1
+		if end > to {
+			end = to
+		}
+
+		logs, err := s.filterLogs(ctx, from, end)
+		if isRangeTooLarge(err) && s.logRange > 1 {
+			s.logRange /= 2
+			log.Printf("eth_getLogs 区间过大，缩小到 %d 个区块: %v", s.logRange, err)
+			continue
+		}
+		if err != nil {
+			return done, err
+		}
+
+		if err := s.processLogs(ctx, end, logs); err != nil {
+			return done, err
+		}
+		done = end
+		from = end + 1
+
+		// 成功后逐步恢复区间大小
+		if s.logRange < s.maxLogRange {
+			s.logRange *= 2
+			if s.logRange > s.maxLogRange {
+				s.logRange = s.maxLogRange
+			}
+		}
+	}
+	return done, nil
+}
+
+// filterLogs 查询 [from, to] 内发往监控地址的 Transfer 日志
+// 收款地址按 maxTopicAddresses 分批放进 to topic，结果按区块和日志序号合并
+func (s *Scanner) filterLogs(ctx context.Context, from, to uint64) ([]types.Log, error) {
+	var chunks [][]common.Hash
+	if watch := s.logFilter.Watch; watch != nil {
+		addrs := watch.List()
+		if len(addrs) == 0 {
+			return nil, nil // 没有监控地址
+		}
+		for start := 0; start < len(addrs); start += maxTopicAddresses {
+			end := min(start+maxTopicAddresses, len(addrs))
+			chunk := make([]common.Hash, 0, end-start)
+			for _, addr := range addrs[start:end] {
+				chunk = append(chunk, common.BytesToHash(addr.Bytes()))
+			}
+			chunks = append(chunks, chunk)
+		}
+	} else {
+		chunks = [][]common.Hash{nil} // 不限收款地址
+	}
+
+	var logs []types.Log
+	for _, chunk := range chunks {
+		topics := [][]common.Hash{{transferTopic}}
+		if chunk != nil {
+			topics = append(topics, nil, chunk)
+		}
+		part, err := s.client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: s.logFilter.Contracts,
+			Topics:    topics,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get logs %d-%d: %w", from, to, err)
+		}
+		logs = append(logs, part...)
+	}
+
+	if len(chunks) > 1 {
+		sort.Slice(logs, func(i, j int) bool {
+			if logs[i].BlockNumber != logs[j].BlockNumber {
+				return logs[i].BlockNumber < logs[j].BlockNumber
+			}
+			return logs[i].Index < logs[j].Index
+		})
+	}
+	return logs, nil
+}
+
+// processLogs 获取命中日志的区块和收据并交给处理器，最后推进到 end
+func (s *Scanner) processLogs(ctx context.Context, end uint64, logs []types.Log) error {
+	// 按区块分组，同一区块内按交易顺序
+	byBlock := make(map[uint64][]types.Log)
+	var numbers []uint64
+	for _, l := range logs {
+		if l.Removed {
+			continue
+		}
+		if _, ok := byBlock[l.BlockNumber]; !ok {
+			numbers = append(numbers, l.BlockNumber)
+		}
+		byBlock[l.BlockNumber] = append(byBlock[l.BlockNumber], l)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	for _, n := range numbers {
+		data, err := s.fetchLogBlock(ctx, n, byBlock[n])
+		if err != nil {
+			return err
+		}
+		if err := s.dispatch(ctx, data, s.handlers); err != nil {
+			return err
+		}
+	}
+
+	// 区间末尾的区块：推进确认跟踪器、提交游标
+	header, err := s.client.HeaderByNumber(ctx, new(big.Int).SetUint64(end))
+	if err != nil {
+		return fmt.Errorf("get header %d: %w", end, err)
+	}
+	block := types.NewBlockWithHeader(header)
+	if len(numbers) == 0 || numbers[len(numbers)-1] != end {
+		for _, handler := range s.handlers {
+			err := s.runHandler(ctx, handler, block, nil, func() error {
+				return handler.HandleBlock(ctx, block)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.saveCursor(ctx, block); err != nil {
+		return fmt.Errorf("save cursor %d: %w", end, err)
+	}
+	return nil
+}
+
+// fetchLogBlock 获取区块和命中日志的交易收据
+func (s *Scanner) fetchLogBlock(ctx context.Context, n uint64, logs []types.Log) (*blockData, error) {
+	block, err := s.client.BlockByNumber(ctx, new(big.Int).SetUint64(n))
+	if err != nil {
+		return nil, fmt.Errorf("get block %d: %w", n, err)
+	}
+	if block.Hash() != logs[0].BlockHash {
+		// 查询日志之后区块被替换，下一轮重试
+		return nil, fmt.Errorf("block %d changed since eth_getLogs", n)
+	}
+
+	seen := make(map[common.Hash]bool)
+	var txs []*types.Transaction
+	for _, l := range logs {
+		if seen[l.TxHash] {
+			continue
+		}
+		seen[l.TxHash] = true
+		tx := block.Transaction(l.TxHash)
+		if tx == nil {
+			return nil, fmt.Errorf("tx %s not in block %d", l.TxHash.Hex(), n)
+		}
+		txs = append(txs, tx)
+	}
+
+	receipts, err := s.fetchReceipts(ctx, block, txs)
+	if err != nil {
+		return nil, err
+	}
+	return &blockData{block: block, receipts: receipts}, nil
+}
+
+// isRangeTooLarge 节点是否因为区间或结果过多拒绝了 eth_getLogs
+// 各家节点的错误码和文案不统一，只能按关键字判断
+func isRangeTooLarge(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32005 {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, keyword := range []string{
+		"block range",
+		"range too large",
+		"range is too large",
+		"too many",
+		"query returned more than",
+		"limit exceeded",
+		"response size",
+		"exceed maximum",
+	} {
+		if strings.Contains(msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
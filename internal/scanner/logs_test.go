@@ -0,0 +1,301 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+// logChain 日志模式测试用的链：区块 10..20，部分区块有代币转账
+type logChain struct {
+	*rpctest.Server
+	blocks   map[uint64]*types.Block
+	receipts map[common.Hash][]*types.Receipt // 按区块哈希
+	logs     []types.Log
+	maxRange uint64 // eth_getLogs 单次允许的最大区块跨度
+
+	mu      sync.Mutex
+	queries [][2]uint64 // 成功的 eth_getLogs 区间
+	topics  []int       // 每次查询 to topic 中的地址数
+}
+
+// newLogChain 按 区块 -> 收款地址 构造转账
+func newLogChain(t *testing.T, transfers map[uint64]common.Address) *logChain {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+
+	c := &logChain{
+		Server:   rpctest.NewServer(),
+		blocks:   make(map[uint64]*types.Block),
+		receipts: make(map[common.Hash][]*types.Receipt),
+		maxRange: 1 << 20,
+	}
+	t.Cleanup(c.Close)
+
+	for n := uint64(10); n <= 20; n++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(n), Difficulty: common.Big0}
+		to, ok := transfers[n]
+		if !ok {
+			c.blocks[n] = types.NewBlock(header, nil, nil, trie.NewStackTrie(nil))
+			continue
+		}
+
+		data := append(append([]byte{}, transferSelector...), common.LeftPadBytes(to.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(big.NewInt(int64(n)).Bytes(), 32)...)
+		tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID: big.NewInt(1), Nonce: n, To: &testToken, Gas: 60000, Data: data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &types.Log{
+			Address:     testToken,
+			Topics:      []common.Hash{transferTopic, common.BytesToHash(sender.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:        common.LeftPadBytes(big.NewInt(int64(n)).Bytes(), 32),
+			BlockNumber: n,
+			TxHash:      tx.Hash(),
+		}
+		receipt := &types.Receipt{
+			Type:        tx.Type(),
+			Status:      types.ReceiptStatusSuccessful,
+			Logs:        []*types.Log{l},
+			TxHash:      tx.Hash(),
+			BlockNumber: header.Number,
+		}
+		receipt.Bloom = types.CreateBloom(receipt)
+
+		block := types.NewBlock(header, &types.Body{Transactions: []*types.Transaction{tx}}, []*types.Receipt{receipt}, trie.NewStackTrie(nil))
+		l.BlockHash = block.Hash()
+		receipt.BlockHash = block.Hash()
+		c.blocks[n] = block
+		c.receipts[block.Hash()] = []*types.Receipt{receipt}
+		c.logs = append(c.logs, *l)
+	}
+
+	c.SetHead(20)
+	c.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var number hexutil.Uint64
+		if err := json.Unmarshal(params[0], &number); err != nil {
+			return nil, err
+		}
+		block, ok := c.blocks[uint64(number)]
+		if !ok {
+			return nil, nil
+		}
+		return rpctest.Block(block), nil
+	})
+	c.Handle("eth_getBlockReceipts", func(params []json.RawMessage) (interface{}, error) {
+		var ref rpc.BlockNumberOrHash
+		if err := json.Unmarshal(params[0], &ref); err != nil {
+			return nil, err
+		}
+		hash, _ := ref.Hash()
+		return c.receipts[hash], nil
+	})
+	c.Handle("eth_getLogs", c.getLogs)
+	return c
+}
+
+func (c *logChain) getLogs(params []json.RawMessage) (interface{}, error) {
+	var q struct {
+		FromBlock hexutil.Uint64  `json:"fromBlock"`
+		ToBlock   hexutil.Uint64  `json:"toBlock"`
+		Topics    [][]common.Hash `json:"topics"`
+	}
+	if err := json.Unmarshal(params[0], &q); err != nil {
+		return nil, err
+	}
+	from, to := uint64(q.FromBlock), uint64(q.ToBlock)
+	if to-from+1 > c.maxRange {
+		return nil, &rpctest.Error{Code: -32005, Message: "query returned more than 10000 results"}
+	}
+
+	recipients := make(map[common.Hash]bool)
+	if len(q.Topics) > 2 {
+		for _, topic := range q.Topics[2] {
+			recipients[topic] = true
+		}
+	}
+	c.mu.Lock()
+	c.queries = append(c.queries, [2]uint64{from, to})
+	c.topics = append(c.topics, len(recipients))
+	c.mu.Unlock()
+
+	logs := []types.Log{}
+	for _, l := range c.logs {
+		if l.BlockNumber < from || l.BlockNumber > to {
+			continue
+		}
+		if len(recipients) > 0 && !recipients[l.Topics[2]] {
+			continue
+		}
+		logs = append(logs, l)
+	}
+	return logs, nil
+}
+
+// blockRecorder 记录 HandleBlock 收到的区块号
+type blockRecorder struct {
+	blocks []uint64
+}
+
+func (r *blockRecorder) HandleBlock(ctx context.Context, block *types.Block) error {
+	r.blocks = append(r.blocks, block.NumberU64())
+	return nil
+}
+
+func (r *blockRecorder) WantsReceipt(tx *types.Transaction) bool { return false }
+
+func (r *blockRecorder) HandleTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	return nil
+}
+
+func (r *blockRecorder) HandleRollback(ctx context.Context, fromBlock uint64) error { return nil }
+
+// newLogScanner 连接 logChain 创建日志模式扫块器
+func newLogScanner(t *testing.T, c *logChain, watch *AddressSet, logRange uint64) *Scanner {
+	t.Helper()
+	pool, err := rpcpool.Dial(context.Background(), []string{c.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	s, err := New(Config{
+		Client:     pool,
+		StartBlock: 10,
+		Mode:       ModeLogs,
+		LogRange:   logRange,
+		LogFilter:  LogFilter{Contracts: []common.Address{testToken}, Watch: watch},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScanLogsRangeHalving(t *testing.T) {
+	c := newLogChain(t, map[uint64]common.Address{11: testWatched, 13: testWatched, 15: testSender})
+	c.maxRange = 4
+	// 重组移除的日志不处理
+	removed := c.logs[0]
+	removed.BlockNumber, removed.Removed = 12, true
+	c.logs = append(c.logs, removed)
+
+	var deposits []*Deposit
+	recorder := &blockRecorder{}
+	s := newLogScanner(t, c, NewAddressSet(testWatched.Hex()), 16)
+	s.AddHandler(NewTokenDepositHandler(s.logFilter.Watch, []Token{{Address: testToken, Decimals: 6}},
+		func(ctx context.Context, d *Deposit) error { deposits = append(deposits, d); return nil }))
+	s.AddHandler(recorder)
+
+	done, err := s.scanLogs(context.Background(), 10, 20)
+	if err != nil || done != 20 {
+		t.Fatalf("scanLogs = %d, %v", done, err)
+	}
+
+	// 区间连续覆盖 10..20，且每段都不超过节点限制
+	next := uint64(10)
+	for _, q := range c.queries {
+		if q[0] != next || q[1]-q[0]+1 > c.maxRange {
+			t.Errorf("unexpected query %v (next %d)", q, next)
+		}
+		next = q[1] + 1
+	}
+	if next != 21 {
+		t.Errorf("queries %v do not cover 10..20", c.queries)
+	}
+
+	if len(deposits) != 2 || deposits[0].BlockNumber != 11 || deposits[1].BlockNumber != 13 {
+		t.Errorf("deposits = %+v", deposits)
+	}
+	// 命中日志的区块按顺序处理，每段末尾推进一次（末尾区块已处理过时不重复）
+	want := []uint64{11, 13, 17, 20}
+	if len(recorder.blocks) != len(want) {
+		t.Fatalf("HandleBlock = %v, want %v", recorder.blocks, want)
+	}
+	for i := range want {
+		if recorder.blocks[i] != want[i] {
+			t.Fatalf("HandleBlock = %v, want %v", recorder.blocks, want)
+		}
+	}
+}
+
+func TestFilterLogsTopicChunks(t *testing.T) {
+	addrs := make([]common.Address, 2500)
+	for i := range addrs {
+		addrs[i] = testAddress(i)
+	}
+	watch := NewAddressSet()
+	watch.Replace(addrs)
+
+	c := newLogChain(t, map[uint64]common.Address{11: addrs[2400], 12: addrs[0], 14: addrs[1200], 16: testSender})
+	s := newLogScanner(t, c, watch, 16)
+
+	logs, err := s.filterLogs(context.Background(), 10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for _, n := range c.topics {
+		if n == 0 || n > maxTopicAddresses {
+			t.Errorf("topic chunk size = %d", n)
+		}
+		total += n
+	}
+	if len(c.topics) != 3 || total != len(addrs) {
+		t.Errorf("chunks = %v, want 3 chunks covering %d addresses", c.topics, len(addrs))
+	}
+
+	// 各批结果合并后按区块排序
+	if len(logs) != 3 || logs[0].BlockNumber != 11 || logs[1].BlockNumber != 12 || logs[2].BlockNumber != 14 {
+		t.Errorf("logs = %+v", logs)
+	}
+}
+
+// codeError 带错误码的 JSON-RPC 错误（实现 rpc.Error）
+type codeError struct {
+	code int
+	msg  string
+}
+
+func (e codeError) Error() string  { return e.msg }
+func (e codeError) ErrorCode() int { return e.code }
+
+func TestIsRangeTooLarge(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{codeError{-32005, "rate limited"}, true},
+		{codeError{-32000, "rate limited"}, false},
+		{errors.New("query returned more than 10000 results"), true},
+		{errors.New("eth_getLogs: block range is too large"), true},
+		{errors.New("exceed maximum block range: 5000"), true},
+		{errors.New("Log response size exceeded"), true},
+		{errors.New("execution reverted"), false},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isRangeTooLarge(tt.err); got != tt.want {
+			t.Errorf("isRangeTooLarge(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// blockData 预取好的区块和收据
+type blockData struct {
+	block    *types.Block
+	receipts map[common.Hash]*types.Receipt
+}
+
+// fetchResult 单个区块的预取结果
+type fetchResult struct {
+	data *blockData
+	err  error
+}
+
+// pipeline 并发预取 [from, to] 区间的区块（收据按 handlers 的需要获取），并严格按区块顺序调用 process
+//
+// 最多同时有 s.concurrency 个区块在预取或等待处理，
+// 任一区块获取失败、process 返回错误或 ctx 取消时停止，之后的区块不会被处理。
+func (s *Scanner) pipeline(ctx context.Context, from, to uint64, handlers []Handler, process func(*blockData) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	slots := make(chan struct{}, s.concurrency)
+	futures := make(chan chan fetchResult, s.concurrency)
+
+	// 按顺序派发预取任务，futures 保持区块顺序
+	go func() {
+		defer close(futures)
+		for n := from; n <= to; n++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			future := make(chan fetchResult, 1)
+			futures <- future
+
+			go func(n uint64) {
+				data, err := s.fetchBlock(ctx, n, handlers)
+				future <- fetchResult{data: data, err: err}
+			}(n)
+		}
+	}()
+
+	for future := range futures {
+		result := <-future
+		<-slots // 处理前释放槽位，让下一个区块开始预取
+
+		// 已取消时预取好的区块也不再处理
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if result.err != nil {
+			return result.err
+		}
+		if err := process(result.data); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
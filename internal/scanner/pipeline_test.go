@@ -0,0 +1,172 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+// delayNode 区块 10..19 的测试节点，越早的区块返回越慢，预取完成的顺序与区块顺序相反
+type delayNode struct {
+	*rpctest.Server
+	failAt uint64 // 返回错误的区块，0 表示不出错
+
+	mu      sync.Mutex
+	fetched []uint64 // 预取完成的顺序
+}
+
+func newDelayNode(t *testing.T) *delayNode {
+	t.Helper()
+	node := &delayNode{Server: rpctest.NewServer()}
+	t.Cleanup(node.Close)
+	node.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var number hexutil.Uint64
+		if err := json.Unmarshal(params[0], &number); err != nil {
+			return nil, err
+		}
+		n := uint64(number)
+		time.Sleep(time.Duration(20-n) * 5 * time.Millisecond)
+
+		node.mu.Lock()
+		node.fetched = append(node.fetched, n)
+		node.mu.Unlock()
+		if n == node.failAt {
+			return nil, &rpctest.Error{Code: -32000, Message: "header not found"}
+		}
+		header := &types.Header{Number: new(big.Int).SetUint64(n), Difficulty: common.Big0}
+		return rpctest.Block(types.NewBlock(header, nil, nil, trie.NewStackTrie(nil))), nil
+	})
+	return node
+}
+
+func newPipelineScanner(t *testing.T, node *delayNode) *Scanner {
+	t.Helper()
+	pool, err := rpcpool.Dial(context.Background(), []string{node.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	s, err := New(Config{Client: pool, StartBlock: 10, Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPipelineOrder(t *testing.T) {
+	node := newDelayNode(t)
+	s := newPipelineScanner(t, node)
+
+	var processed []uint64
+	err := s.pipeline(context.Background(), 10, 19, nil, func(data *blockData) error {
+		processed = append(processed, data.block.NumberU64())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range processed {
+		if n != uint64(10+i) {
+			t.Fatalf("processed = %v, want 10..19 in order", processed)
+		}
+	}
+	if len(processed) != 10 {
+		t.Fatalf("processed %d blocks, want 10", len(processed))
+	}
+
+	// 确认预取确实是乱序完成的，否则上面的检查没有意义
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	inOrder := true
+	for i := 1; i < len(node.fetched); i++ {
+		if node.fetched[i] < node.fetched[i-1] {
+			inOrder = false
+		}
+	}
+	if inOrder {
+		t.Errorf("fetched = %v, want out of order", node.fetched)
+	}
+}
+
+func TestPipelineStop(t *testing.T) {
+	errStop := errors.New("stop")
+
+	t.Run("process error", func(t *testing.T) {
+		s := newPipelineScanner(t, newDelayNode(t))
+		var processed []uint64
+		err := s.pipeline(context.Background(), 10, 19, nil, func(data *blockData) error {
+			processed = append(processed, data.block.NumberU64())
+			if data.block.NumberU64() == 12 {
+				return errStop
+			}
+			return nil
+		})
+		if !errors.Is(err, errStop) {
+			t.Fatalf("err = %v, want %v", err, errStop)
+		}
+		if len(processed) != 3 {
+			t.Errorf("processed = %v, want 10..12", processed)
+		}
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		node := newDelayNode(t)
+		node.failAt = 13
+		s := newPipelineScanner(t, node)
+		var processed []uint64
+		err := s.pipeline(context.Background(), 10, 19, nil, func(data *blockData) error {
+			processed = append(processed, data.block.NumberU64())
+			return nil
+		})
+		if err == nil {
+			t.Fatal("want fetch error")
+		}
+		// 出错区块之前的区块照常处理，之后的不处理
+		if len(processed) != 3 || processed[2] != 12 {
+			t.Errorf("processed = %v, want 10..12", processed)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		node := newDelayNode(t)
+		s := newPipelineScanner(t, node)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var processed []uint64
+		err := s.pipeline(ctx, 10, 19, nil, func(data *blockData) error {
+			processed = append(processed, data.block.NumberU64())
+			if data.block.NumberU64() == 11 {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+		if len(processed) > 2 {
+			t.Errorf("processed = %v after cancel, want 10..11", processed)
+		}
+
+		// 取消后不再派发新的预取：最多是取消时已在进行中的 concurrency 个
+		time.Sleep(100 * time.Millisecond)
+		node.mu.Lock()
+		defer node.mu.Unlock()
+		if len(node.fetched) > 2+4 {
+			t.Errorf("fetched %v after cancel", node.fetched)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// FailureAction 处理器重试仍失败后的处理方式
+type FailureAction string
+
+const (
+	FailSkip       FailureAction = "skip"        // 记录日志后继续（默认）
+	FailHalt       FailureAction = "halt"        // 停止扫块器，停在失败的区块等待人工处理
+	FailDeadLetter FailureAction = "dead_letter" // 写入死信存储，之后通过 cli replay 重放
+)
+
+const (
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// ErrorPolicy 处理器出错时的策略
+// 先按指数退避重试 Retries 次，仍失败再执行 OnFailure
+type ErrorPolicy struct {
+	Retries   int           // 重试次数（0 表示不重试）
+	Backoff   time.Duration // 首次重试间隔，之后每次翻倍（默认 500ms，最长 30s）
+	OnFailure FailureAction // 重试耗尽后的处理（空表示 skip）
+}
+
+// HaltError 处理器按 halt 策略停止扫块
+// 游标停在失败区块的前一个区块，修复后重启即从该区块继续
+type HaltError struct {
+	Handler string
+	Block   uint64
+	TxHash  common.Hash // 区块级处理失败时为空
+	Err     error
+}
+
+func (e *HaltError) Error() string {
+	if e.TxHash == (common.Hash{}) {
+		return fmt.Sprintf("handler %s halted at block %d: %v", e.Handler, e.Block, e.Err)
+	}
+	return fmt.Sprintf("handler %s halted at block %d tx %s: %v", e.Handler, e.Block, e.TxHash.Hex(), e.Err)
+}
+
+func (e *HaltError) Unwrap() error {
+	return e.Err
+}
+
+// SetErrorPolicy 设置单个处理器的错误策略（覆盖 Config.ErrorPolicy）
+func (s *Scanner) SetErrorPolicy(handler Handler, policy ErrorPolicy) {
+	s.policies[handler] = policy
+}
+
+// policyFor 处理器生效的错误策略
+func (s *Scanner) policyFor(handler Handler) ErrorPolicy {
+	if policy, ok := s.policies[handler]; ok {
+		return policy
+	}
+	return s.errorPolicy
+}
+
+// runHandler 调用处理器并按策略处理错误
+// 只有 halt 策略会返回错误（*HaltError），其余情况都继续扫块
+func (s *Scanner) runHandler(ctx context.Context, handler Handler, block *types.Block, tx *types.Transaction, call func() error) error {
+	kind := "block"
+	if tx != nil {
+		kind = "tx"
+	}
+
+	policy := s.policyFor(handler)
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err = call()
+		s.observeHandler(handler, kind, start)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.Retries {
+			break
+		}
+
+		log.Printf("处理器 %s 处理区块 %d 失败，%s 后重试 (%d/%d): %v",
+			HandlerName(handler), block.NumberU64(), backoff, attempt+1, policy.Retries, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+
+	letter := DeadLetter{
+		ChainID:     s.chainID.Uint64(),
+		Handler:     HandlerName(handler),
+		BlockNumber: block.NumberU64(),
+		BlockHash:   block.Hash(),
+		Error:       err.Error(),
+		CreatedAt:   time.Now(),
+	}
+	if tx != nil {
+		letter.TxHash = tx.Hash()
+	}
+
+	switch policy.OnFailure {
+	case FailHalt:
+		return &HaltError{Handler: letter.Handler, Block: letter.BlockNumber, TxHash: letter.TxHash, Err: err}
+
+	case FailDeadLetter:
+		if s.deadLetters == nil {
+			return &HaltError{Handler: letter.Handler, Block: letter.BlockNumber, TxHash: letter.TxHash,
+				Err: fmt.Errorf("no dead letter store: %w", err)}
+		}
+		if serr := s.deadLetters.Put(ctx, letter); serr != nil {
+			// 死信写不进去就不能跳过，否则会丢数据
+			return &HaltError{Handler: letter.Handler, Block: letter.BlockNumber, TxHash: letter.TxHash,
+				Err: fmt.Errorf("save dead letter: %v: %w", serr, err)}
+		}
+		log.Printf("⚠️ 处理器 %s 失败，已写入死信: block=%d, tx=%s: %v",
+			letter.Handler, letter.BlockNumber, letter.TxHash.Hex(), err)
+		return nil
+
+	default:
+		if tx != nil {
+			log.Printf("处理交易 %s 失败: %v", tx.Hash(), err)
+		} else {
+			log.Printf("处理区块 %d 失败: %v", block.NumberU64(), err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestRunHandlerPolicy(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileDeadLetterStore(filepath.Join(t.TempDir(), "dead_letters.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewDepositTracker(1, 1, nil, nil)
+	s := &Scanner{
+		chainID:     big.NewInt(1),
+		policies:    make(map[Handler]ErrorPolicy),
+		deadLetters: store,
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100)})
+
+	// 重试后成功
+	calls := 0
+	s.SetErrorPolicy(handler, ErrorPolicy{Retries: 2, Backoff: time.Millisecond, OnFailure: FailHalt})
+	err = s.runHandler(ctx, handler, block, nil, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("retry: err = %v, calls = %d", err, calls)
+	}
+
+	// 重试耗尽后停止
+	err = s.runHandler(ctx, handler, block, nil, func() error { return errors.New("down") })
+	var halt *HaltError
+	if !errors.As(err, &halt) || halt.Block != 100 || halt.Handler != "DepositTracker" {
+		t.Fatalf("halt: err = %v", err)
+	}
+
+	// 写入死信后继续
+	s.SetErrorPolicy(handler, ErrorPolicy{Backoff: time.Millisecond, OnFailure: FailDeadLetter})
+	if err := s.runHandler(ctx, handler, block, nil, func() error { return errors.New("down") }); err != nil {
+		t.Fatalf("dead letter: err = %v", err)
+	}
+	letters, _ := store.List(ctx, 1)
+	if len(letters) != 1 || letters[0].BlockNumber != 100 || letters[0].Error != "down" {
+		t.Fatalf("dead letters = %+v", letters)
+	}
+
+	// 重新打开后仍然存在
+	reopened, err := NewFileDeadLetterStore(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if letters, _ := reopened.List(ctx, 1); len(letters) != 1 {
+		t.Fatalf("reopened dead letters = %d", len(letters))
+	}
+}
@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"wallet/pkg/rpcpool"
+)
+
+func TestScanRange(t *testing.T) {
+	ctx := context.Background()
+	node := newDelayNode(t)
+	node.SetHead(19)
+	pool, err := rpcpool.Dial(ctx, []string{node.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	cursor, err := NewFileCursorStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := Cursor{BlockNumber: 9, BlockHash: common.HexToHash("0x09")}
+	if err := cursor.Save(ctx, 1, saved); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(Config{Client: pool, Cursor: cursor, Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &blockRecorder{}
+	if err := s.ScanRange(ctx, 10, 19, recorder); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.blocks) != 10 || recorder.blocks[0] != 10 || recorder.blocks[9] != 19 {
+		t.Fatalf("handled blocks = %v, want 10..19", recorder.blocks)
+	}
+
+	// 区间扫描不影响正常扫块：游标、起始区块和重组窗口都不变
+	if got, err := cursor.Load(ctx, 1); err != nil || got == nil || *got != saved {
+		t.Errorf("cursor = %+v, %v, want %+v", got, err, saved)
+	}
+	if s.startBlock != 10 {
+		t.Errorf("startBlock = %d, want 10", s.startBlock)
+	}
+	if hash, ok := s.window.get(9); len(s.window.hashes) != 1 || !ok || hash != saved.BlockHash {
+		t.Errorf("window = %v, want only the cursor block", s.window.hashes)
+	}
+
+	// 还没出块的区间直接拒绝
+	if err := s.ScanRange(ctx, 15, 25, recorder); err == nil {
+		t.Error("ScanRange beyond head: want error")
+	}
+}
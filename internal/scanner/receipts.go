@@ -0,0 +1,122 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// receiptBatchSize 单个 JSON-RPC 批量请求最多包含的收据数
+const receiptBatchSize = 100
+
+// ReceiptFilter 处理器可选实现：声明关心哪些交易
+// 未实现该接口的处理器会收到区块内所有交易的收据
+type ReceiptFilter interface {
+	WantsReceipt(tx *types.Transaction) bool
+}
+
+// wantsReceipt 处理器是否需要这笔交易
+func wantsReceipt(h Handler, tx *types.Transaction) bool {
+	filter, ok := h.(ReceiptFilter)
+	return !ok || filter.WantsReceipt(tx)
+}
+
+// wantedTransactions 至少有一个处理器关心的交易
+func wantedTransactions(block *types.Block, handlers []Handler) []*types.Transaction {
+	var wanted []*types.Transaction
+	for _, tx := range block.Transactions() {
+		for _, handler := range handlers {
+			if wantsReceipt(handler, tx) {
+				wanted = append(wanted, tx)
+				break
+			}
+		}
+	}
+	return wanted
+}
+
+// fetchReceipts 获取交易收据
+// 关心的交易较多时用 eth_getBlockReceipts 一次取回整个区块，
+// 较少或节点不支持时用 JSON-RPC 批量请求只取需要的收据
+func (s *Scanner) fetchReceipts(ctx context.Context, block *types.Block, txs []*types.Transaction) (map[common.Hash]*types.Receipt, error) {
+	receipts := make(map[common.Hash]*types.Receipt, len(txs))
+	if len(txs) == 0 {
+		return receipts, nil
+	}
+
+	if !s.noBlockRcpts.Load() && len(txs)*2 > len(block.Transactions()) {
+		all, err := s.client.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(block.Hash(), false))
+		switch {
+		case err == nil:
+			if len(all) != len(block.Transactions()) {
+				return nil, fmt.Errorf("block %d: got %d receipts for %d txs",
+					block.NumberU64(), len(all), len(block.Transactions()))
+			}
+			for _, receipt := range all {
+				receipts[receipt.TxHash] = receipt
+			}
+			return receipts, nil
+
+		case isMethodUnsupported(err):
+			log.Printf("节点不支持 eth_getBlockReceipts，改用批量请求 (chainID=%s): %v", s.chainID, err)
+			s.noBlockRcpts.Store(true)
+
+		default:
+			return nil, fmt.Errorf("get block receipts %d: %w", block.NumberU64(), err)
+		}
+	}
+
+	for start := 0; start < len(txs); start += receiptBatchSize {
+		end := start + receiptBatchSize
+		if end > len(txs) {
+			end = len(txs)
+		}
+
+		batch := make([]rpc.BatchElem, 0, end-start)
+		results := make([]*types.Receipt, end-start)
+		for i, tx := range txs[start:end] {
+			batch = append(batch, rpc.BatchElem{
+				Method: "eth_getTransactionReceipt",
+				Args:   []interface{}{tx.Hash()},
+				Result: &results[i],
+			})
+		}
+
+		if err := s.client.BatchCallContext(ctx, batch); err != nil {
+			return nil, fmt.Errorf("batch get receipts: %w", err)
+		}
+
+		for i, elem := range batch {
+			hash := txs[start+i].Hash()
+			if elem.Error != nil {
+				return nil, fmt.Errorf("get receipt %s: %w", hash, elem.Error)
+			}
+			if results[i] == nil {
+				return nil, fmt.Errorf("receipt %s not found", hash)
+			}
+			receipts[hash] = results[i]
+		}
+	}
+
+	return receipts, nil
+}
+
+// isMethodUnsupported 判断节点是否不支持该 RPC 方法
+func isMethodUnsupported(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "method not found") ||
+		strings.Contains(msg, "not supported") ||
+		strings.Contains(msg, "does not exist") ||
+		strings.Contains(msg, "not available")
+}
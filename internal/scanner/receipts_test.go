@@ -0,0 +1,174 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+// newReceiptNode 测试节点：一个包含 4 笔交易的区块，按交易哈希提供收据
+func newReceiptNode(t *testing.T) (*rpctest.Server, *types.Block) {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+
+	header := &types.Header{Number: big.NewInt(100), Difficulty: common.Big0}
+	var txs []*types.Transaction
+	var receipts []*types.Receipt
+	for i := 0; i < 4; i++ {
+		tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID: big.NewInt(1), Nonce: uint64(i), To: &testWatched, Gas: 21000, Value: big.NewInt(1),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+		receipts = append(receipts, &types.Receipt{
+			Type:        tx.Type(),
+			Status:      types.ReceiptStatusSuccessful,
+			Logs:        []*types.Log{},
+			TxHash:      tx.Hash(),
+			BlockNumber: header.Number,
+		})
+	}
+	block := types.NewBlock(header, &types.Body{Transactions: txs}, receipts, trie.NewStackTrie(nil))
+	byHash := make(map[common.Hash]*types.Receipt)
+	for _, receipt := range receipts {
+		receipt.BlockHash = block.Hash()
+		byHash[receipt.TxHash] = receipt
+	}
+
+	node := rpctest.NewServer()
+	t.Cleanup(node.Close)
+	node.Result("eth_getBlockReceipts", receipts)
+	node.Handle("eth_getTransactionReceipt", func(params []json.RawMessage) (interface{}, error) {
+		var hash common.Hash
+		if err := json.Unmarshal(params[0], &hash); err != nil {
+			return nil, err
+		}
+		return byHash[hash], nil
+	})
+	return node, block
+}
+
+func newReceiptScanner(t *testing.T, node *rpctest.Server) *Scanner {
+	t.Helper()
+	pool, err := rpcpool.Dial(context.Background(), []string{node.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	s, err := New(Config{Client: pool, StartBlock: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// txFilter 只关心指定交易的处理器
+type txFilter struct {
+	blockRecorder
+	want common.Hash
+}
+
+func (f *txFilter) WantsReceipt(tx *types.Transaction) bool { return tx.Hash() == f.want }
+
+func TestFetchReceipts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("block receipts", func(t *testing.T) {
+		node, block := newReceiptNode(t)
+		s := newReceiptScanner(t, node)
+
+		receipts, err := s.fetchReceipts(ctx, block, block.Transactions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(receipts) != 4 {
+			t.Fatalf("got %d receipts, want 4", len(receipts))
+		}
+		if n := node.Calls("eth_getBlockReceipts"); n != 1 {
+			t.Errorf("eth_getBlockReceipts calls = %d, want 1", n)
+		}
+		if n := node.Calls("eth_getTransactionReceipt"); n != 0 {
+			t.Errorf("eth_getTransactionReceipt calls = %d, want 0", n)
+		}
+	})
+
+	t.Run("unsupported falls back to batch", func(t *testing.T) {
+		node, block := newReceiptNode(t)
+		node.Fail("eth_getBlockReceipts", -32601, "the method eth_getBlockReceipts does not exist/is not available")
+		s := newReceiptScanner(t, node)
+
+		for i := 0; i < 2; i++ {
+			receipts, err := s.fetchReceipts(ctx, block, block.Transactions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tx := range block.Transactions() {
+				if receipts[tx.Hash()] == nil {
+					t.Fatalf("missing receipt %s", tx.Hash())
+				}
+			}
+		}
+		// 第一次失败后记住节点不支持，不再尝试
+		if n := node.Calls("eth_getBlockReceipts"); n != 1 {
+			t.Errorf("eth_getBlockReceipts calls = %d, want 1", n)
+		}
+		if n := node.Calls("eth_getTransactionReceipt"); n != 8 {
+			t.Errorf("eth_getTransactionReceipt calls = %d, want 8", n)
+		}
+	})
+
+	t.Run("receipt filter", func(t *testing.T) {
+		node, block := newReceiptNode(t)
+		s := newReceiptScanner(t, node)
+		want := block.Transactions()[2].Hash()
+
+		// 未实现 ReceiptFilter 的处理器需要所有交易
+		all := wantedTransactions(block, []Handler{&txFilter{want: want}, &noFilterHandler{}})
+		if len(all) != 4 {
+			t.Fatalf("handler without filter: got %d txs, want 4", len(all))
+		}
+
+		txs := wantedTransactions(block, []Handler{&txFilter{want: want}, &blockRecorder{}})
+		if len(txs) != 1 || txs[0].Hash() != want {
+			t.Fatalf("wanted = %v, want only %s", txs, want)
+		}
+		// 只需要少数交易时按交易批量获取，不取整个区块
+		receipts, err := s.fetchReceipts(ctx, block, txs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(receipts) != 1 || receipts[want] == nil {
+			t.Fatalf("receipts = %v, want only %s", receipts, want)
+		}
+		if n := node.Calls("eth_getBlockReceipts"); n != 0 {
+			t.Errorf("eth_getBlockReceipts calls = %d, want 0", n)
+		}
+		if n := node.Calls("eth_getTransactionReceipt"); n != 1 {
+			t.Errorf("eth_getTransactionReceipt calls = %d, want 1", n)
+		}
+	})
+}
+
+// noFilterHandler 没有实现 ReceiptFilter 的处理器
+type noFilterHandler struct{}
+
+func (noFilterHandler) HandleBlock(ctx context.Context, block *types.Block) error { return nil }
+
+func (noFilterHandler) HandleTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	return nil
+}
+
+func (noFilterHandler) HandleRollback(ctx context.Context, fromBlock uint64) error { return nil }
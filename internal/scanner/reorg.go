@@ -0,0 +1,142 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// defaultReorgDepth 默认保留的最近区块哈希数量
+const defaultReorgDepth = 64
+
+// ErrDeepReorg 重组深度超过哈希窗口，找不到分叉点
+// 无法自动回滚，扫块器停止等待人工处理（重试同一个区块只会得到同样的结果）
+var ErrDeepReorg = errors.New("reorg deeper than window")
+
+// ReorgError 检测到链重组，fork 为新旧链最后一个相同的区块
+type ReorgError struct {
+	Block    uint64      // 发现父哈希不匹配的区块
+	Fork     uint64      // 分叉点
+	ForkHash common.Hash // 分叉点区块哈希
+}
+
+func (e *ReorgError) Error() string {
+	return fmt.Sprintf("chain reorg at block %d, fork point %d", e.Block, e.Fork)
+}
+
+// blockWindow 最近已处理区块的哈希窗口
+type blockWindow struct {
+	depth  uint64
+	hashes map[uint64]common.Hash
+	lowest uint64 // 窗口内最小的区块号
+}
+
+func newBlockWindow(depth uint64) *blockWindow {
+	if depth == 0 {
+		depth = defaultReorgDepth
+	}
+	return &blockWindow{
+		depth:  depth,
+		hashes: make(map[uint64]common.Hash),
+	}
+}
+
+// add 记录区块哈希，并淘汰超出窗口的旧区块
+func (w *blockWindow) add(number uint64, hash common.Hash) {
+	if len(w.hashes) == 0 || number < w.lowest {
+		w.lowest = number
+	}
+	w.hashes[number] = hash
+
+	for number >= w.depth && w.lowest <= number-w.depth {
+		delete(w.hashes, w.lowest)
+		w.lowest++
+	}
+}
+
+func (w *blockWindow) get(number uint64) (common.Hash, bool) {
+	hash, ok := w.hashes[number]
+	return hash, ok
+}
+
+// truncate 删除 >= from 的区块
+func (w *blockWindow) truncate(from uint64) {
+	for number := range w.hashes {
+		if number >= from {
+			delete(w.hashes, number)
+		}
+	}
+}
+
+// checkReorg 校验区块的父哈希，不匹配时向前查找分叉点
+// 沿旧链的父哈希逐个与规范链比对，最多比对 ReorgDepth 个区块；
+// 窗口里没有的旧区块（重启后窗口只恢复了游标区块）按哈希向节点查询取父哈希，
+// 节点也没有时才算作超过窗口
+func (s *Scanner) checkReorg(ctx context.Context, block *types.Block) error {
+	number := block.NumberU64()
+	if number == 0 {
+		return nil
+	}
+
+	parent, ok := s.window.get(number - 1)
+	if !ok || parent == block.ParentHash() {
+		return nil
+	}
+
+	known := parent
+	for n := number - 1; ; n-- {
+		header, err := s.client.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return fmt.Errorf("get header %d: %w", n, err)
+		}
+		if header.Hash() == known {
+			return &ReorgError{Block: number, Fork: n, ForkHash: known}
+		}
+		if n == 0 || number-n >= s.window.depth {
+			break
+		}
+
+		prev, ok := s.window.get(n - 1)
+		if !ok {
+			old, err := s.client.HeaderByHash(ctx, known)
+			if errors.Is(err, ethereum.NotFound) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("get header %s: %w", known.Hex(), err)
+			}
+			prev = old.ParentHash
+		}
+		known = prev
+	}
+
+	return fmt.Errorf("%w: block %d, window %d blocks", ErrDeepReorg, number, s.window.depth)
+}
+
+// rollback 回滚到分叉点：通知处理器、清理哈希窗口、重置游标
+// 任一处理器回滚失败都不清理窗口和游标，返回错误，下一轮重新发现重组并重试回滚
+func (s *Scanner) rollback(ctx context.Context, reorg *ReorgError) error {
+	log.Printf("链重组: 回滚到区块 %d (chainID=%s)", reorg.Fork, s.chainID)
+
+	for _, handler := range s.handlers {
+		if err := handler.HandleRollback(ctx, reorg.Fork+1); err != nil {
+			return fmt.Errorf("%s rollback from %d: %w", HandlerName(handler), reorg.Fork+1, err)
+		}
+	}
+
+	if s.cursor != nil {
+		cursor := Cursor{BlockNumber: reorg.Fork, BlockHash: reorg.ForkHash}
+		if err := s.cursor.Save(ctx, s.chainID.Uint64(), cursor); err != nil {
+			return fmt.Errorf("save cursor %d: %w", reorg.Fork, err)
+		}
+	}
+	s.window.truncate(reorg.Fork + 1)
+	s.window.add(reorg.Fork, reorg.ForkHash)
+	return nil
+}
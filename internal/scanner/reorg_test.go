@@ -0,0 +1,274 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"wallet/internal/metrics"
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+func TestBlockWindow(t *testing.T) {
+	w := newBlockWindow(3)
+	for n := uint64(10); n <= 14; n++ {
+		w.add(n, common.Hash{byte(n)})
+	}
+
+	// 只保留最近 3 个区块
+	for n := uint64(10); n <= 11; n++ {
+		if _, ok := w.get(n); ok {
+			t.Errorf("block %d should be evicted", n)
+		}
+	}
+	for n := uint64(12); n <= 14; n++ {
+		if _, ok := w.get(n); !ok {
+			t.Errorf("block %d should be kept", n)
+		}
+	}
+
+	// 回滚后从分叉点继续
+	w.truncate(13)
+	if _, ok := w.get(13); ok {
+		t.Error("block 13 should be truncated")
+	}
+	w.add(13, common.HexToHash("0x13"))
+	if hash, _ := w.get(13); hash != common.HexToHash("0x13") {
+		t.Errorf("block 13 hash = %s", hash.Hex())
+	}
+	if _, ok := w.get(12); !ok {
+		t.Error("block 12 should survive truncate")
+	}
+}
+
+func TestDeepReorgHaltsScanner(t *testing.T) {
+	node := rpctest.NewServer()
+	defer node.Close()
+
+	// 窗口内记录的 10..12 在节点上都已经换成了别的区块
+	headers := make(map[uint64]*types.Header)
+	for n := uint64(10); n <= 13; n++ {
+		headers[n] = &types.Header{
+			Number:      new(big.Int).SetUint64(n),
+			Difficulty:  common.Big0,
+			UncleHash:   types.EmptyUncleHash,
+			TxHash:      types.EmptyTxsHash,
+			ReceiptHash: types.EmptyReceiptsHash,
+			Extra:       []byte("new chain"),
+		}
+	}
+	headers[13].ParentHash = common.Hash{0xff}
+	node.SetHead(13)
+	node.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var number hexutil.Uint64
+		if err := json.Unmarshal(params[0], &number); err != nil {
+			return nil, err
+		}
+		header, ok := headers[uint64(number)]
+		if !ok {
+			return nil, nil
+		}
+		return rpctest.Block(types.NewBlockWithHeader(header)), nil
+	})
+
+	pool, err := rpcpool.Dial(context.Background(), []string{node.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	s, err := New(Config{Client: pool, StartBlock: 13, BatchSize: 1, ReorgDepth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := uint64(10); n <= 12; n++ {
+		s.window.add(n, common.Hash{byte(n)})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = s.Start(ctx, 10*time.Millisecond)
+	if !errors.Is(err, ErrDeepReorg) {
+		t.Fatalf("Start = %v, want ErrDeepReorg", err)
+	}
+	if got := testutil.ToFloat64(metrics.ScannerHalted.WithLabelValues("1", "deep_reorg")); got != 1 {
+		t.Errorf("halted metric = %v", got)
+	}
+}
+
+// reorgChain 测试节点上的两条分叉：10 为公共区块，旧链 11..12 已被新链 11..13 取代
+// 旧链区块仍可按哈希查询（节点保留了侧链区块）
+type reorgChain struct {
+	*rpctest.Server
+	base     *types.Header
+	old, new map[uint64]*types.Header
+}
+
+func newReorgChain(t *testing.T) *reorgChain {
+	t.Helper()
+	header := func(n uint64, parent common.Hash, extra string) *types.Header {
+		return &types.Header{
+			Number:      new(big.Int).SetUint64(n),
+			ParentHash:  parent,
+			Difficulty:  common.Big0,
+			UncleHash:   types.EmptyUncleHash,
+			TxHash:      types.EmptyTxsHash,
+			ReceiptHash: types.EmptyReceiptsHash,
+			Extra:       []byte(extra),
+		}
+	}
+	c := &reorgChain{Server: rpctest.NewServer(), old: make(map[uint64]*types.Header), new: make(map[uint64]*types.Header)}
+	t.Cleanup(c.Close)
+
+	c.base = header(10, common.Hash{}, "")
+	byHash := map[common.Hash]*types.Header{c.base.Hash(): c.base}
+	for _, fork := range []struct {
+		headers map[uint64]*types.Header
+		to      uint64
+		extra   string
+	}{{c.old, 12, "old"}, {c.new, 13, "new"}} {
+		parent := c.base
+		for n := uint64(11); n <= fork.to; n++ {
+			parent = header(n, parent.Hash(), fork.extra)
+			fork.headers[n] = parent
+			byHash[parent.Hash()] = parent
+		}
+	}
+
+	c.SetHead(13)
+	c.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var number hexutil.Uint64
+		if err := json.Unmarshal(params[0], &number); err != nil {
+			return nil, err
+		}
+		header, ok := c.new[uint64(number)]
+		if uint64(number) == 10 {
+			header, ok = c.base, true
+		}
+		if !ok {
+			return nil, nil
+		}
+		return rpctest.Block(types.NewBlockWithHeader(header)), nil
+	})
+	c.Handle("eth_getBlockByHash", func(params []json.RawMessage) (interface{}, error) {
+		var hash common.Hash
+		if err := json.Unmarshal(params[0], &hash); err != nil {
+			return nil, err
+		}
+		header, ok := byHash[hash]
+		if !ok {
+			return nil, nil
+		}
+		return rpctest.Block(types.NewBlockWithHeader(header)), nil
+	})
+	return c
+}
+
+// rollbackRecorder 记录处理的区块和回滚起点，failRollbacks 次回滚返回错误
+type rollbackRecorder struct {
+	blockRecorder
+	rollbacks     []uint64
+	failRollbacks int
+}
+
+func (r *rollbackRecorder) HandleRollback(ctx context.Context, fromBlock uint64) error {
+	r.rollbacks = append(r.rollbacks, fromBlock)
+	if r.failRollbacks > 0 {
+		r.failRollbacks--
+		return errors.New("rollback failed")
+	}
+	return nil
+}
+
+// newReorgScanner 已处理完旧链 12 的扫块器
+// restarted 为 true 时模拟重启：哈希窗口只有从游标恢复的区块 12
+func newReorgScanner(t *testing.T, c *reorgChain, restarted bool, h Handler) (*Scanner, CursorStore) {
+	t.Helper()
+	pool, err := rpcpool.Dial(context.Background(), []string{c.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	cursor, err := NewFileCursorStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cursor.Save(context.Background(), 1, Cursor{BlockNumber: 12, BlockHash: c.old[12].Hash()}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(Config{Client: pool, Cursor: cursor, BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restarted {
+		s.window.add(10, c.base.Hash())
+		s.window.add(11, c.old[11].Hash())
+	}
+	s.AddHandler(h)
+	return s, cursor
+}
+
+func TestShallowReorg(t *testing.T) {
+	for _, restarted := range []bool{false, true} {
+		c := newReorgChain(t)
+		h := &rollbackRecorder{}
+		s, cursor := newReorgScanner(t, c, restarted, h)
+		ctx := context.Background()
+
+		// 区块 13 的父哈希对不上，沿旧链找到分叉点 10，回滚 >= 11
+		next, more, err := s.scanNext(ctx, s.startBlock)
+		if err != nil || next != 11 || !more {
+			t.Fatalf("restarted=%v: scanNext = %d, %v, %v, want 11, true", restarted, next, more, err)
+		}
+		if len(h.rollbacks) != 1 || h.rollbacks[0] != 11 {
+			t.Errorf("restarted=%v: rollbacks = %v, want [11]", restarted, h.rollbacks)
+		}
+		saved, _ := cursor.Load(ctx, 1)
+		if saved.BlockNumber != 10 || saved.BlockHash != c.base.Hash() {
+			t.Errorf("restarted=%v: cursor = %d %s, want fork point 10", restarted, saved.BlockNumber, saved.BlockHash.Hex())
+		}
+
+		// 重新扫描新链
+		if _, _, err := s.scanNext(ctx, next); err != nil {
+			t.Fatal(err)
+		}
+		if len(h.blocks) != 3 || h.blocks[0] != 11 || h.blocks[2] != 13 {
+			t.Errorf("restarted=%v: rescanned %v, want 11..13", restarted, h.blocks)
+		}
+		saved, _ = cursor.Load(ctx, 1)
+		if saved.BlockHash != c.new[13].Hash() {
+			t.Errorf("restarted=%v: cursor = %d, want new block 13", restarted, saved.BlockNumber)
+		}
+	}
+}
+
+func TestReorgRollbackFailure(t *testing.T) {
+	c := newReorgChain(t)
+	h := &rollbackRecorder{failRollbacks: 1}
+	s, cursor := newReorgScanner(t, c, true, h)
+	ctx := context.Background()
+
+	// 回滚失败：停在区块 13，游标不回退，下一轮重试
+	next, _, err := s.scanNext(ctx, 13)
+	if err != nil || next != 13 {
+		t.Fatalf("scanNext = %d, %v, want 13", next, err)
+	}
+	saved, _ := cursor.Load(ctx, 1)
+	if saved.BlockNumber != 12 || len(h.blocks) != 0 {
+		t.Fatalf("failed rollback moved on: cursor %d, blocks %v", saved.BlockNumber, h.blocks)
+	}
+
+	next, _, err = s.scanNext(ctx, next)
+	if err != nil || next != 11 || len(h.rollbacks) != 2 {
+		t.Fatalf("retry: scanNext = %d, %v, rollbacks %v", next, err, h.rollbacks)
+	}
+}
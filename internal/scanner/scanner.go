@@ -2,52 +2,113 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"wallet/internal/metrics"
+	"wallet/pkg/rpcpool"
 )
 
 // Scanner 区块扫描器
 type Scanner struct {
-	client        *ethclient.Client
+	client        *rpcpool.Pool
+	ownsClient    bool // 自己创建的连接池，Close 时关闭
 	chainID       *big.Int
 	startBlock    uint64
 	confirmBlocks uint64
 	batchSize     int
+	concurrency   int // 并发预取区块数
 	handlers      []Handler
+	cursor        CursorStore   // 为 nil 时不持久化扫块进度
+	window        *blockWindow  // 最近区块哈希，用于检测链重组
+	noBlockRcpts  atomic.Bool   // 节点不支持 eth_getBlockReceipts
+	wsURLs        []string      // newHeads 订阅地址
+	wsActive      atomic.Bool   // 订阅是否正常
+	headStall     time.Duration // 多久没有推送新区块视为订阅失效
+	errorPolicy   ErrorPolicy   // 处理器默认错误策略
+	policies      map[Handler]ErrorPolicy
+	deadLetters   DeadLetterStore
+	mode          ScanMode
+	logFilter     LogFilter
+	logRange      uint64 // 当前 eth_getLogs 区块跨度（自适应）
+	maxLogRange   uint64
 }
 
 // Handler 区块处理器接口
 type Handler interface {
 	HandleBlock(ctx context.Context, block *types.Block) error
 	HandleTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error
+	// HandleRollback 链重组时调用，>= fromBlock 的区块已失效，处理器应撤销相关记录
+	HandleRollback(ctx context.Context, fromBlock uint64) error
 }
 
 // Config 扫描器配置
 type Config struct {
 	RPCUrl        string
-	StartBlock    uint64 // 0 表示最新区块
-	ConfirmBlocks uint64 // 确认区块数
-	BatchSize     int
+	Client        *rpcpool.Pool   // 共享的 RPC 连接池（可选，优先于 RPCUrl）
+	StartBlock    uint64          // 0 表示最新区块
+	ConfirmBlocks uint64          // 确认区块数
+	BatchSize     int             // 每轮最多扫描的区块数
+	Cursor        CursorStore     // 游标存储（可选），重启后从上次处理完的区块继续
+	ReorgDepth    uint64          // 保留多少个最近区块哈希用于检测重组（0 使用默认值 64）
+	Concurrency   int             // 并发预取区块和收据的数量（<=1 表示顺序扫描）
+	WSURLs        []string        // WebSocket 地址（可选），订阅 newHeads 实时触发扫描
+	ErrorPolicy   ErrorPolicy     // 处理器默认错误策略（默认记录日志后继续），可用 SetErrorPolicy 单独设置
+	DeadLetters   DeadLetterStore // 死信存储（dead_letter 策略需要）
+	Mode          ScanMode        // 扫块模式（默认 blocks）
+	LogFilter     LogFilter       // logs 模式的过滤条件
+	LogRange      uint64          // logs 模式单次查询的最大区块跨度（默认 2000）
 }
 
 // New 创建扫描器
 func New(cfg Config) (*Scanner, error) {
-	client, err := ethclient.Dial(cfg.RPCUrl)
-	if err != nil {
-		return nil, fmt.Errorf("connect to rpc: %w", err)
+	client := cfg.Client
+	if client == nil {
+		pool, err := rpcpool.Dial(context.Background(), []string{cfg.RPCUrl}, rpcpool.Config{})
+		if err != nil {
+			return nil, err
+		}
+		client = pool
 	}
+	ownsClient := cfg.Client == nil
 
 	chainID, err := client.ChainID(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("get chain id: %w", err)
 	}
 
+	window := newBlockWindow(cfg.ReorgDepth)
 	startBlock := cfg.StartBlock
+	if cfg.Cursor != nil {
+		// 优先从游标恢复
+		saved, err := cfg.Cursor.Load(context.Background(), chainID.Uint64())
+		if err != nil {
+			return nil, fmt.Errorf("load cursor: %w", err)
+		}
+		if saved != nil {
+			startBlock = saved.BlockNumber + 1
+			window.add(saved.BlockNumber, saved.BlockHash)
+		}
+	}
+	concurrency := cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	mode := cfg.Mode
+	if mode == "" {
+		mode = ModeBlocks
+	}
+	logRange := cfg.LogRange
+	if logRange == 0 {
+		logRange = defaultLogRange
+	}
+
 	if startBlock == 0 {
 		// 从最新区块开始
 		latest, err := client.BlockNumber(context.Background())
@@ -59,11 +120,24 @@ func New(cfg Config) (*Scanner, error) {
 
 	return &Scanner{
 		client:        client,
+		ownsClient:    ownsClient,
 		chainID:       chainID,
 		startBlock:    startBlock,
 		confirmBlocks: cfg.ConfirmBlocks,
 		batchSize:     cfg.BatchSize,
+		concurrency:   concurrency,
 		handlers:      []Handler{},
+		cursor:        cfg.Cursor,
+		window:        window,
+		wsURLs:        cfg.WSURLs,
+		headStall:     headStallTimeout,
+		errorPolicy:   cfg.ErrorPolicy,
+		policies:      make(map[Handler]ErrorPolicy),
+		deadLetters:   cfg.DeadLetters,
+		mode:          mode,
+		logFilter:     cfg.LogFilter,
+		logRange:      logRange,
+		maxLogRange:   logRange,
 	}, nil
 }
 
@@ -72,13 +146,27 @@ func (s *Scanner) AddHandler(h Handler) {
 	s.handlers = append(s.handlers, h)
 }
 
+// Handlers 已添加的处理器（按调用顺序）
+func (s *Scanner) Handlers() []Handler {
+	return append([]Handler(nil), s.handlers...)
+}
+
 // Start 开始扫描
+// 配置了 WSURLs 时由 newHeads 订阅驱动，订阅断开期间退回按 interval 轮询；
+// 订阅正常时仍每 safetyPollTicks 个周期兜底轮询一次，防止漏掉推送
 func (s *Scanner) Start(ctx context.Context, interval time.Duration) error {
 	log.Printf("扫块器启动: chainID=%s, startBlock=%d", s.chainID, s.startBlock)
 
 	currentBlock := s.startBlock
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	skipped := 0 // 订阅正常时跳过的轮询次数
+
+	// 新区块通知（容量 1，多个通知合并为一次扫描）
+	trigger := make(chan struct{}, 1)
+	if len(s.wsURLs) > 0 {
+		go s.watchHeads(ctx, trigger)
+	}
 
 	for {
 		select {
@@ -87,68 +175,207 @@ func (s *Scanner) Start(ctx context.Context, interval time.Duration) error {
 			return ctx.Err()
 
 		case <-ticker.C:
-			// 获取最新区块
-			latestBlock, err := s.client.BlockNumber(ctx)
-			if err != nil {
-				log.Printf("获取最新区块失败: %v", err)
-				continue
+			if s.wsActive.Load() && skipped < safetyPollTicks-1 {
+				skipped++
+				continue // 订阅正常时降低轮询频率
 			}
+			skipped = 0
 
-			// 计算需要扫描的区块（减去确认数）
-			confirmedBlock := latestBlock - s.confirmBlocks
-			if currentBlock > confirmedBlock {
-				// 还没有新的已确认区块
-				continue
-			}
+		case <-trigger:
+		}
 
-			// 批量扫描
-			endBlock := currentBlock + uint64(s.batchSize)
-			if endBlock > confirmedBlock {
-				endBlock = confirmedBlock
-			}
+		var (
+			more bool
+			err  error
+		)
+		currentBlock, more, err = s.scanNext(ctx, currentBlock)
+		if err != nil {
+			log.Printf("扫块器停止: %v", err)
+			return err
+		}
+		if more {
+			// 还在追块，不等下一个区块或下一轮轮询
+			notify(trigger)
+		}
+	}
+}
 
-			log.Printf("扫描区块: %d -> %d", currentBlock, endBlock)
+// scanNext 从 currentBlock 开始扫描一批区块
+// 返回下一个要扫描的区块，以及是否还有已出块但未扫描的区块；
+// 返回错误表示扫块器需要停止（处理器按 halt 策略失败，或重组超过窗口）
+func (s *Scanner) scanNext(ctx context.Context, currentBlock uint64) (uint64, bool, error) {
+	// 获取最新区块
+	latestBlock, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		log.Printf("获取最新区块失败: %v", err)
+		return currentBlock, false, nil
+	}
+	chain := s.chainID.String()
+	metrics.HeadBlock.WithLabelValues(chain).Set(float64(latestBlock))
+	if currentBlock > 0 {
+		metrics.LagBlocks.WithLabelValues(chain).Set(lagBlocks(latestBlock, currentBlock-1))
+	}
 
-			for blockNum := currentBlock; blockNum <= endBlock; blockNum++ {
-				if err := s.scanBlock(ctx, blockNum); err != nil {
-					log.Printf("扫描区块 %d 失败: %v", blockNum, err)
-					// 继续扫描下一个区块
-				}
-			}
+	// 计算需要扫描的区块（减去确认数）
+	// 负载均衡到落后的节点时链头可能比已扫描的区块还低
+	if latestBlock < s.confirmBlocks {
+		return currentBlock, false, nil
+	}
+	confirmedBlock := latestBlock - s.confirmBlocks
+	if currentBlock > confirmedBlock {
+		// 还没有新的已确认区块
+		return currentBlock, false, nil
+	}
+
+	// 批量扫描
+	endBlock := currentBlock + uint64(s.batchSize)
+	if s.mode == ModeLogs {
+		endBlock = currentBlock + s.maxLogRange - 1
+	}
+	if endBlock > confirmedBlock {
+		endBlock = confirmedBlock
+	}
+
+	log.Printf("扫描区块: %d -> %d", currentBlock, endBlock)
+
+	start := time.Now()
+	scanned := 0
+	defer func() {
+		if scanned > 0 {
+			metrics.BlocksPerSecond.WithLabelValues(chain).Set(float64(scanned) / time.Since(start).Seconds())
+		}
+	}()
 
-			currentBlock = endBlock + 1
+	if s.mode == ModeLogs {
+		var done uint64
+		done, err = s.scanLogs(ctx, currentBlock, endBlock)
+		if done+1 > currentBlock {
+			scanned = int(done + 1 - currentBlock)
+			metrics.BlocksScanned.WithLabelValues(chain).Add(float64(scanned))
+			metrics.ScannedBlock.WithLabelValues(chain).Set(float64(done))
+			metrics.LagBlocks.WithLabelValues(chain).Set(lagBlocks(latestBlock, done))
+			currentBlock = done + 1
 		}
+	} else {
+		err = s.scanBlocks(ctx, currentBlock, endBlock, func(n uint64) {
+			currentBlock = n + 1
+			scanned++
+			metrics.BlocksScanned.WithLabelValues(chain).Inc()
+			metrics.ScannedBlock.WithLabelValues(chain).Set(float64(n))
+			metrics.LagBlocks.WithLabelValues(chain).Set(lagBlocks(latestBlock, n))
+		})
 	}
+
+	var (
+		reorg *ReorgError
+		halt  *HaltError
+	)
+	switch {
+	case errors.As(err, &reorg):
+		if err := s.rollback(ctx, reorg); err != nil {
+			// 停在发现重组的区块，下一轮重新检测并重试回滚，不会越过失败的回滚继续扫描
+			log.Printf("回滚失败，下一轮重试: %v", err)
+			return currentBlock, false, nil
+		}
+		return reorg.Fork + 1, true, nil
+	case errors.Is(err, ErrDeepReorg):
+		log.Printf("🚨 链重组超过窗口，扫块器停止在区块 %d，需要人工处理: %v", currentBlock, err)
+		metrics.ScannerHalted.WithLabelValues(chain, "deep_reorg").Set(1)
+		return currentBlock, false, err
+	case errors.As(err, &halt):
+		metrics.ScannerHalted.WithLabelValues(chain, "handler").Set(1)
+		return currentBlock, false, halt
+	case err != nil:
+		// 停在失败的区块，下一轮重试，避免漏块
+		log.Printf("扫描区块 %d 失败: %v", currentBlock, err)
+		return currentBlock, false, nil
+	}
+
+	return currentBlock, currentBlock <= confirmedBlock, nil
+}
+
+// lagBlocks 已扫描区块落后链头的区块数，节点链头落后时为 0
+func lagBlocks(head, scanned uint64) float64 {
+	if head < scanned {
+		return 0
+	}
+	return float64(head - scanned)
 }
 
-// scanBlock 扫描单个区块
-func (s *Scanner) scanBlock(ctx context.Context, blockNum uint64) error {
-	// 获取区块详情
-	block, err := s.client.BlockByNumber(ctx, big.NewInt(int64(blockNum)))
+// scanBlocks 逐块扫描 [from, to]，每处理完一个区块调用 done
+// 并发预取，按区块顺序交给处理器；处理完一个区块才提交一个区块的游标
+func (s *Scanner) scanBlocks(ctx context.Context, from, to uint64, done func(n uint64)) error {
+	return s.pipeline(ctx, from, to, s.handlers, func(data *blockData) error {
+		if err := s.processBlock(ctx, data); err != nil {
+			return err
+		}
+		if err := s.saveCursor(ctx, data.block); err != nil {
+			return fmt.Errorf("save cursor %d: %w", data.block.NumberU64(), err)
+		}
+		s.window.add(data.block.NumberU64(), data.block.Hash())
+		done(data.block.NumberU64())
+		return nil
+	})
+}
+
+// fetchBlock 获取区块及处理器需要的收据（可并发调用）
+func (s *Scanner) fetchBlock(ctx context.Context, blockNum uint64, handlers []Handler) (*blockData, error) {
+	block, err := s.client.BlockByNumber(ctx, new(big.Int).SetUint64(blockNum))
 	if err != nil {
-		return fmt.Errorf("get block %d: %w", blockNum, err)
+		return nil, fmt.Errorf("get block %d: %w", blockNum, err)
+	}
+
+	receipts, err := s.fetchReceipts(ctx, block, wantedTransactions(block, handlers))
+	if err != nil {
+		return nil, err
+	}
+
+	return &blockData{block: block, receipts: receipts}, nil
+}
+
+// processBlock 按顺序调用处理器（只能在扫块主循环中调用）
+func (s *Scanner) processBlock(ctx context.Context, data *blockData) error {
+	block := data.block
+
+	// 父哈希与已处理的区块不一致说明发生了重组
+	if err := s.checkReorg(ctx, block); err != nil {
+		return err
 	}
 
+	return s.dispatch(ctx, data, s.handlers)
+}
+
+// dispatch 把区块和交易交给处理器（保持区块内顺序）
+func (s *Scanner) dispatch(ctx context.Context, data *blockData, handlers []Handler) error {
+	block := data.block
+
 	// 调用区块处理器
-	for _, handler := range s.handlers {
-		if err := handler.HandleBlock(ctx, block); err != nil {
-			log.Printf("处理区块 %d 失败: %v", blockNum, err)
+	for _, handler := range handlers {
+		err := s.runHandler(ctx, handler, block, nil, func() error {
+			return handler.HandleBlock(ctx, block)
+		})
+		if err != nil {
+			return err
 		}
 	}
 
-	// 处理区块中的每笔交易
+	// 处理区块中的每笔交易（保持区块内顺序）
 	for _, tx := range block.Transactions() {
-		// 获取交易收据
-		receipt, err := s.client.TransactionReceipt(ctx, tx.Hash())
-		if err != nil {
-			log.Printf("获取交易收据失败 %s: %v", tx.Hash(), err)
-			continue
+		receipt, ok := data.receipts[tx.Hash()]
+		if !ok {
+			continue // 没有处理器关心这笔交易
 		}
 
 		// 调用交易处理器
-		for _, handler := range s.handlers {
-			if err := handler.HandleTransaction(ctx, tx, receipt); err != nil {
-				log.Printf("处理交易 %s 失败: %v", tx.Hash(), err)
+		for _, handler := range handlers {
+			if !wantsReceipt(handler, tx) {
+				continue
+			}
+			err := s.runHandler(ctx, handler, block, tx, func() error {
+				return handler.HandleTransaction(ctx, tx, receipt)
+			})
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -156,9 +383,42 @@ func (s *Scanner) scanBlock(ctx context.Context, blockNum uint64) error {
 	return nil
 }
 
+// observeHandler 记录处理器耗时
+func (s *Scanner) observeHandler(h Handler, kind string, start time.Time) {
+	metrics.HandlerDuration.
+		WithLabelValues(s.chainID.String(), HandlerName(h), kind).
+		Observe(time.Since(start).Seconds())
+}
+
+// HandlerName 处理器类型名，例如 DepositHandler
+// 用于指标标签、按处理器配置错误策略和标记死信
+func HandlerName(h Handler) string {
+	name := fmt.Sprintf("%T", h)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// saveCursor 提交扫块进度
+func (s *Scanner) saveCursor(ctx context.Context, block *types.Block) error {
+	if s.cursor == nil {
+		return nil
+	}
+	return s.cursor.Save(ctx, s.chainID.Uint64(), Cursor{
+		BlockNumber: block.NumberU64(),
+		BlockHash:   block.Hash(),
+	})
+}
+
+// Client 扫块使用的 RPC 连接池（也可用于 debug_trace 等原始调用）
+func (s *Scanner) Client() *rpcpool.Pool {
+	return s.client
+}
+
 // Close 关闭扫描器
 func (s *Scanner) Close() {
-	if s.client != nil {
+	if s.ownsClient {
 		s.client.Close()
 	}
 }
@@ -0,0 +1,36 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"wallet/internal/metrics"
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+func TestScanNextLaggingNode(t *testing.T) {
+	node := rpctest.NewServer()
+	defer node.Close()
+	pool, err := rpcpool.Dial(context.Background(), []string{node.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	s, err := New(Config{Client: pool, StartBlock: 10, ConfirmBlocks: 12, BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 节点链头低于已扫描的区块，也低于确认数：不能下溢成巨大的延迟或扫描区间
+	node.SetHead(5)
+	next, more, err := s.scanNext(context.Background(), 10)
+	if err != nil || next != 10 || more {
+		t.Fatalf("scanNext = %d, %v, %v, want 10, false", next, more, err)
+	}
+	if lag := testutil.ToFloat64(metrics.LagBlocks.WithLabelValues("1")); lag != 0 {
+		t.Errorf("lag = %v, want 0", lag)
+	}
+}
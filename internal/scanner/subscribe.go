@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	minResubscribeDelay = time.Second
+	maxResubscribeDelay = time.Minute
+
+	// headStallTimeout 订阅连接正常但这么久没有推送新区块，视为失效并重新订阅
+	headStallTimeout = 2 * time.Minute
+	// safetyPollTicks 订阅正常时每隔这么多个轮询周期仍兜底扫描一次
+	safetyPollTicks = 10
+)
+
+// watchHeads 订阅 newHeads，每个新区块头发一次通知
+// 订阅失败或断开时切换到下一个地址，按指数退避重连；
+// 断开期间 wsActive 为 false，Start 退回轮询，重连成功后立即补扫一次
+func (s *Scanner) watchHeads(ctx context.Context, trigger chan<- struct{}) {
+	delay := minResubscribeDelay
+	for i := 0; ; i++ {
+		url := s.wsURLs[i%len(s.wsURLs)]
+
+		err := s.subscribeHeads(ctx, url, trigger)
+		s.wsActive.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			delay = minResubscribeDelay // 订阅曾经正常，重新开始退避
+		}
+		if err != nil {
+			log.Printf("newHeads 订阅失败 (%s): %v, %s 后重连，期间使用轮询", url, err, delay)
+		} else {
+			log.Printf("newHeads 订阅断开 (%s), %s 后重连，期间使用轮询", url, delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxResubscribeDelay {
+			delay = maxResubscribeDelay
+		}
+	}
+}
+
+// subscribeHeads 建立一次订阅并转发通知，直到订阅出错
+// 返回 nil 表示订阅成功建立过（之后才断开）
+func (s *Scanner) subscribeHeads(ctx context.Context, url string, trigger chan<- struct{}) error {
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	heads := make(chan *types.Header, 16)
+	sub, err := client.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	log.Printf("newHeads 订阅成功: chainID=%s, %s", s.chainID, url)
+	s.wsActive.Store(true)
+	notify(trigger) // 断开期间可能漏掉通知，先补扫一次
+
+	// 部分节点会停止推送但不断开连接，长时间没有新区块时主动重连
+	stall := time.NewTimer(s.headStall)
+	defer stall.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			log.Printf("newHeads 订阅出错: %v", err)
+			return nil
+		case <-stall.C:
+			log.Printf("newHeads %s 内没有推送新区块 (%s)", s.headStall, url)
+			return nil
+		case <-heads:
+			notify(trigger)
+			stall.Reset(s.headStall)
+		}
+	}
+}
+
+// notify 非阻塞通知，已有未处理的通知时合并
+func notify(trigger chan<- struct{}) {
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,168 @@
+package scanner
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+// headService 测试用的 eth_subscribe("newHeads") 服务
+type headService struct {
+	mu       sync.Mutex
+	count    int // 建立过的订阅数
+	notifier *rpc.Notifier
+	sub      *rpc.Subscription
+}
+
+func (h *headService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	h.mu.Lock()
+	h.count++
+	h.notifier, h.sub = notifier, sub
+	h.mu.Unlock()
+	return sub, nil
+}
+
+// push 向当前订阅推送一个区块头
+func (h *headService) push(n int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sub != nil {
+		h.notifier.Notify(h.sub.ID, &types.Header{Number: big.NewInt(n), Difficulty: common.Big0})
+	}
+}
+
+func (h *headService) subscriptions() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// newHeadServer 启动 WebSocket 节点，返回 ws:// 地址
+func newHeadServer(t *testing.T) (*headService, string) {
+	t.Helper()
+	service := &headService{}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	ws := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	t.Cleanup(func() {
+		ws.Close()
+		server.Stop()
+	})
+	return service, "ws" + strings.TrimPrefix(ws.URL, "http")
+}
+
+func newSubscribeScanner(t *testing.T, node *rpctest.Server, wsURL string) *Scanner {
+	t.Helper()
+	pool, err := rpcpool.Dial(context.Background(), []string{node.URL}, rpcpool.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	s, err := New(Config{Client: pool, StartBlock: 10, WSURLs: []string{wsURL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// waitFor 轮询等待条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchHeads(t *testing.T) {
+	node := rpctest.NewServer()
+	defer node.Close()
+	service, wsURL := newHeadServer(t)
+	s := newSubscribeScanner(t, node, wsURL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trigger := make(chan struct{}, 1)
+	go s.watchHeads(ctx, trigger)
+
+	// 订阅成功后先补扫一次
+	waitFor(t, "subscription", s.wsActive.Load)
+	select {
+	case <-trigger:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trigger after subscribing")
+	}
+
+	// 每个新区块头触发一次扫描
+	service.push(11)
+	select {
+	case <-trigger:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trigger for new head")
+	}
+}
+
+func TestWatchHeadsStall(t *testing.T) {
+	node := rpctest.NewServer()
+	defer node.Close()
+	service, wsURL := newHeadServer(t)
+	s := newSubscribeScanner(t, node, wsURL)
+	s.headStall = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.watchHeads(ctx, make(chan struct{}, 1))
+
+	// 连接正常但一直没有推送：超时后退回轮询并重新订阅
+	waitFor(t, "first subscription", func() bool { return service.subscriptions() == 1 })
+	waitFor(t, "fallback to polling", func() bool { return !s.wsActive.Load() })
+	waitFor(t, "resubscribe", func() bool { return service.subscriptions() >= 2 })
+}
+
+func TestStartPollingFallback(t *testing.T) {
+	node := rpctest.NewServer()
+	defer node.Close()
+	node.SetHead(5) // 低于起始区块，每次扫描只查询链头
+
+	// 订阅地址连不上：按 interval 轮询
+	s := newSubscribeScanner(t, node, "ws://127.0.0.1:1")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.Start(ctx, 10*time.Millisecond)
+	if n := node.Calls("eth_blockNumber"); n < 10 {
+		t.Errorf("eth_blockNumber calls = %d without subscription, want polling", n)
+	}
+
+	// 订阅正常：只在订阅成功时补扫一次，之后每 safetyPollTicks 个周期兜底一次
+	_, wsURL := newHeadServer(t)
+	s = newSubscribeScanner(t, node, wsURL)
+	before := node.Calls("eth_blockNumber")
+	ctx, cancel = context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.Start(ctx, 10*time.Millisecond)
+	if n := node.Calls("eth_blockNumber") - before; n > 30/safetyPollTicks+2 {
+		t.Errorf("eth_blockNumber calls = %d with active subscription, want reduced polling", n)
+	}
+}
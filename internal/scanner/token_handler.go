@@ -0,0 +1,224 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// transferTopic Transfer(address,address,uint256) 事件签名
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// ERC-20 方法选择器（失败交易没有日志，只能从调用数据识别）
+var (
+	transferSelector     = []byte{0xa9, 0x05, 0x9c, 0xbb} // transfer(address,uint256)
+	transferFromSelector = []byte{0x23, 0xb8, 0x72, 0xdd} // transferFrom(address,address,uint256)
+)
+
+// Token 允许入账的代币合约
+type Token struct {
+	Address  common.Address
+	Symbol   string
+	Decimals uint8
+}
+
+// TokenDepositHandler ERC-20 代币充值处理器
+// 代币可能经由路由、多签等合约转入，因此不实现 ReceiptFilter，需要区块内所有收据
+type TokenDepositHandler struct {
+	watch      *AddressSet                                       // 监控的地址（可与其他处理器共享）
+	tokens     map[common.Address]Token                          // 代币白名单（按合约地址）
+	callback   func(ctx context.Context, deposit *Deposit) error // 充值回调
+	onFailed   func(deposit *Deposit)                            // 失败充值回调（可选，不入账）
+	onRollback func(fromBlock uint64)                            // 链重组回调（可选）
+}
+
+// NewTokenDepositHandler 创建代币充值处理器
+// 只有 tokens 中的合约发出的 Transfer 事件才会被识别为充值
+func NewTokenDepositHandler(watch *AddressSet, tokens []Token, callback func(context.Context, *Deposit) error) *TokenDepositHandler {
+	tokenMap := make(map[common.Address]Token)
+	for _, token := range tokens {
+		tokenMap[token.Address] = token
+	}
+
+	return &TokenDepositHandler{
+		watch:    watch,
+		tokens:   tokenMap,
+		callback: callback,
+	}
+}
+
+// AddWatchAddress 添加监控地址（并发安全，可在扫块过程中调用）
+func (h *TokenDepositHandler) AddWatchAddress(addr string) {
+	h.watch.Add(common.HexToAddress(addr))
+}
+
+// SetRollbackCallback 设置链重组回调，>= fromBlock 的充值需要冲正
+func (h *TokenDepositHandler) SetRollbackCallback(fn func(fromBlock uint64)) {
+	h.onRollback = fn
+}
+
+// SetFailedCallback 设置失败充值回调
+// 只能识别直接调用白名单代币 transfer / transferFrom 的失败交易，经由其他合约转入的无法识别
+func (h *TokenDepositHandler) SetFailedCallback(fn func(deposit *Deposit)) {
+	h.onFailed = fn
+}
+
+// HandleBlock 处理区块
+func (h *TokenDepositHandler) HandleBlock(ctx context.Context, block *types.Block) error {
+	return nil
+}
+
+// HandleTransaction 解析收据中的 Transfer 事件
+func (h *TokenDepositHandler) HandleTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	// 失败交易没有日志，从调用数据还原充值尝试
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		deposit, ok := h.parseFailedTransfer(tx, receipt)
+		if !ok {
+			return nil
+		}
+
+		token := h.tokens[deposit.TokenAddress]
+		log.Printf("检测到失败的代币充值交易: token=%s, from=%s, to=%s, value=%s, tx=%s",
+			token.Symbol,
+			deposit.From.Hex(),
+			deposit.To.Hex(),
+			formatUnits(deposit.Value, token.Decimals),
+			deposit.TxHash.Hex(),
+		)
+		if h.onFailed != nil {
+			h.onFailed(deposit)
+		}
+		return nil
+	}
+
+	for _, l := range receipt.Logs {
+		deposit, ok := h.parseTransfer(l)
+		if !ok {
+			continue
+		}
+		deposit.Status = receipt.Status
+
+		token := h.tokens[deposit.TokenAddress]
+		log.Printf("检测到代币充值: token=%s, from=%s, to=%s, value=%s, tx=%s, logIndex=%d",
+			token.Symbol,
+			deposit.From.Hex(),
+			deposit.To.Hex(),
+			formatUnits(deposit.Value, token.Decimals),
+			deposit.TxHash.Hex(),
+			deposit.LogIndex,
+		)
+
+		if h.callback != nil {
+			if err := h.callback(ctx, deposit); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// HandleRollback 处理链重组
+func (h *TokenDepositHandler) HandleRollback(ctx context.Context, fromBlock uint64) error {
+	log.Printf("链重组: 区块 %d 及之后的代币充值失效", fromBlock)
+	if h.onRollback != nil {
+		h.onRollback(fromBlock)
+	}
+	return nil
+}
+
+// parseTransfer 解析发往监控地址的 Transfer 事件
+func (h *TokenDepositHandler) parseTransfer(l *types.Log) (*Deposit, bool) {
+	// ERC-721 的 Transfer 有 4 个 topic，这里只处理 ERC-20
+	if l.Removed || len(l.Topics) != 3 || l.Topics[0] != transferTopic || len(l.Data) != 32 {
+		return nil, false
+	}
+
+	token, ok := h.tokens[l.Address]
+	if !ok {
+		return nil, false // 不在白名单的合约（包括仿冒代币）
+	}
+
+	to := common.BytesToAddress(l.Topics[2].Bytes())
+	if !h.watch.Contains(to) {
+		return nil, false
+	}
+
+	value := new(big.Int).SetBytes(l.Data)
+	if value.Sign() == 0 {
+		return nil, false
+	}
+
+	return &Deposit{
+		TxHash:        l.TxHash,
+		BlockNumber:   l.BlockNumber,
+		From:          common.BytesToAddress(l.Topics[1].Bytes()),
+		To:            to,
+		Value:         value,
+		TokenAddress:  token.Address,
+		TokenDecimals: token.Decimals,
+		LogIndex:      l.Index,
+	}, true
+}
+
+// parseFailedTransfer 解析直接调用白名单代币 transfer / transferFrom 且收款方为监控地址的失败交易
+func (h *TokenDepositHandler) parseFailedTransfer(tx *types.Transaction, receipt *types.Receipt) (*Deposit, bool) {
+	if tx.To() == nil {
+		return nil, false
+	}
+	token, ok := h.tokens[*tx.To()]
+	if !ok {
+		return nil, false
+	}
+
+	var (
+		data = tx.Data()
+		from common.Address
+		args []byte
+	)
+	switch {
+	case len(data) == 4+64 && bytes.Equal(data[:4], transferSelector):
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			log.Printf("提取发送者地址失败: %v", err)
+			return nil, false
+		}
+		from, args = sender, data[4:]
+	case len(data) == 4+96 && bytes.Equal(data[:4], transferFromSelector):
+		from, args = common.BytesToAddress(data[4:36]), data[36:]
+	default:
+		return nil, false
+	}
+
+	to := common.BytesToAddress(args[:32])
+	if !h.watch.Contains(to) {
+		return nil, false
+	}
+	value := new(big.Int).SetBytes(args[32:64])
+	if value.Sign() == 0 {
+		return nil, false
+	}
+
+	return &Deposit{
+		TxHash:        tx.Hash(),
+		BlockNumber:   receipt.BlockNumber.Uint64(),
+		From:          from,
+		To:            to,
+		Value:         value,
+		Status:        receipt.Status,
+		TokenAddress:  token.Address,
+		TokenDecimals: token.Decimals,
+	}, true
+}
+
+// formatUnits 按精度格式化代币数量
+func formatUnits(value *big.Int, decimals uint8) string {
+	unit := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	amount := new(big.Float).Quo(new(big.Float).SetInt(value), unit)
+	return amount.Text('f', 6)
+}
@@ -0,0 +1,172 @@
+package scanner
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	testToken   = common.HexToAddress("0xdac17f958d2ee523a2206206994597c13d831ec7")
+	testWatched = common.HexToAddress("0x1f9840a85d5af5bf1d1762f925bdaddc4201f984")
+	testSender  = common.HexToAddress("0x5afe3855358e112b5647b952709e6165e1c1eeee")
+)
+
+// transferLog 构造 Transfer(from, to, value) 日志
+func transferLog(token, from, to common.Address, value int64) *types.Log {
+	return &types.Log{
+		Address: token,
+		Topics: []common.Hash{
+			transferTopic,
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data:        common.BigToHash(big.NewInt(value)).Bytes(),
+		BlockNumber: 100,
+		TxHash:      common.HexToHash("0x01"),
+		Index:       3,
+	}
+}
+
+func TestParseTransfer(t *testing.T) {
+	h := NewTokenDepositHandler(
+		NewAddressSet(testWatched.Hex()),
+		[]Token{{Address: testToken, Symbol: "USDT", Decimals: 6}},
+		nil,
+	)
+
+	tests := []struct {
+		name   string
+		modify func(l *types.Log)
+		ok     bool
+	}{
+		{"正常转入", func(l *types.Log) {}, true},
+		{"不在白名单的代币", func(l *types.Log) { l.Address = common.HexToAddress("0xbad") }, false},
+		{"收款方不是监控地址", func(l *types.Log) { l.Topics[2] = common.BytesToHash(testSender.Bytes()) }, false},
+		{"ERC-721 四个 topic", func(l *types.Log) { l.Topics = append(l.Topics, common.Hash{}) }, false},
+		{"缺少 topic", func(l *types.Log) { l.Topics = l.Topics[:2] }, false},
+		{"事件签名不对", func(l *types.Log) { l.Topics[0] = common.HexToHash("0x02") }, false},
+		{"data 过短", func(l *types.Log) { l.Data = l.Data[:31] }, false},
+		{"data 过长", func(l *types.Log) { l.Data = append(l.Data, 0) }, false},
+		{"空 data", func(l *types.Log) { l.Data = nil }, false},
+		{"已被重组移除", func(l *types.Log) { l.Removed = true }, false},
+		{"零金额", func(l *types.Log) { l.Data = make([]byte, 32) }, false},
+	}
+	for _, tt := range tests {
+		l := transferLog(testToken, testSender, testWatched, 1_000_000)
+		tt.modify(l)
+
+		d, ok := h.parseTransfer(l)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if d.From != testSender || d.To != testWatched || d.TokenAddress != testToken ||
+			d.Value.Int64() != 1_000_000 || d.TokenDecimals != 6 || d.LogIndex != 3 || d.BlockNumber != 100 {
+			t.Errorf("%s: deposit = %+v", tt.name, d)
+		}
+	}
+}
+
+func TestTokenHandleTransaction(t *testing.T) {
+	var deposits []*Deposit
+	h := NewTokenDepositHandler(
+		NewAddressSet(testWatched.Hex()),
+		[]Token{{Address: testToken, Symbol: "USDT", Decimals: 6}},
+		func(ctx context.Context, d *Deposit) error { deposits = append(deposits, d); return nil },
+	)
+
+	// 一笔交易里混有仿冒代币、发往他人和发往监控地址的转账，只入账最后一条
+	receipt := &types.Receipt{
+		Status: types.ReceiptStatusSuccessful,
+		Logs: []*types.Log{
+			transferLog(common.HexToAddress("0xbad"), testSender, testWatched, 5),
+			transferLog(testToken, testWatched, testSender, 7),
+			transferLog(testToken, testSender, testWatched, 9),
+		},
+	}
+	tx := types.NewTx(&types.LegacyTx{To: &testToken})
+	if err := h.HandleTransaction(context.Background(), tx, receipt); err != nil {
+		t.Fatal(err)
+	}
+	if len(deposits) != 1 || deposits[0].Value.Int64() != 9 || deposits[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("deposits = %+v", deposits)
+	}
+}
+
+func TestTokenHandleFailedTransaction(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(1)
+
+	var deposits, failed []*Deposit
+	h := NewTokenDepositHandler(
+		NewAddressSet(testWatched.Hex()),
+		[]Token{{Address: testToken, Symbol: "USDT", Decimals: 6}},
+		func(ctx context.Context, d *Deposit) error { deposits = append(deposits, d); return nil },
+	)
+	h.SetFailedCallback(func(d *Deposit) { failed = append(failed, d) })
+
+	word := func(b []byte) []byte { return common.LeftPadBytes(b, 32) }
+	transfer := func(to common.Address, value int64) []byte {
+		return append(append(append([]byte{}, transferSelector...), word(to.Bytes())...), word(big.NewInt(value).Bytes())...)
+	}
+	transferFrom := append(append([]byte{}, transferFromSelector...), word(testSender.Bytes())...)
+	transferFrom = append(append(transferFrom, word(testWatched.Bytes())...), word(big.NewInt(8).Bytes())...)
+
+	tests := []struct {
+		name     string
+		to       common.Address
+		data     []byte
+		wantFrom common.Address
+		want     int64 // 0 表示不应回调
+	}{
+		{"transfer 到监控地址", testToken, transfer(testWatched, 5), sender, 5},
+		{"transferFrom 到监控地址", testToken, transferFrom, testSender, 8},
+		{"收款方不是监控地址", testToken, transfer(testSender, 5), common.Address{}, 0},
+		{"不在白名单的代币", common.HexToAddress("0xbad"), transfer(testWatched, 5), common.Address{}, 0},
+		{"调用数据截断", testToken, transfer(testWatched, 5)[:40], common.Address{}, 0},
+		{"其他方法", testToken, append([]byte{0x09, 0x5e, 0xa7, 0xb3}, transfer(testWatched, 5)[4:]...), common.Address{}, 0},
+	}
+	for _, tt := range tests {
+		failed = nil
+		to := tt.to
+		tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+			ChainID: chainID, To: &to, Gas: 60000, Data: tt.data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		receipt := &types.Receipt{Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(100)}
+		if err := h.HandleTransaction(context.Background(), tx, receipt); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if tt.want == 0 {
+			if len(failed) != 0 {
+				t.Errorf("%s: 不应回调, got %+v", tt.name, failed[0])
+			}
+			continue
+		}
+		if len(failed) != 1 {
+			t.Errorf("%s: failed = %d, want 1", tt.name, len(failed))
+			continue
+		}
+		d := failed[0]
+		if d.From != tt.wantFrom || d.To != testWatched || d.Value.Int64() != tt.want ||
+			d.TokenAddress != testToken || d.Status != types.ReceiptStatusFailed || d.TxHash != tx.Hash() {
+			t.Errorf("%s: deposit = %+v", tt.name, d)
+		}
+	}
+
+	if len(deposits) != 0 {
+		t.Errorf("失败交易不应入账, got %d", len(deposits))
+	}
+}
@@ -0,0 +1,199 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// CallFrame callTracer 输出的调用帧
+type CallFrame struct {
+	Type  string         `json:"type"` // CALL, DELEGATECALL, STATICCALL, CREATE, CREATE2, SELFDESTRUCT
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Value *hexutil.Big   `json:"value,omitempty"`
+	Error string         `json:"error,omitempty"`
+	Calls []CallFrame    `json:"calls,omitempty"`
+}
+
+// TxTrace 单笔交易的调用树
+type TxTrace struct {
+	TxHash common.Hash `json:"txHash"`
+	Result *CallFrame  `json:"result"`
+}
+
+// BlockTracer 区块调用追踪接口
+type BlockTracer interface {
+	TraceBlock(ctx context.Context, number uint64) ([]TxTrace, error)
+}
+
+// rpcCaller 原始 JSON-RPC 调用（*rpc.Client 和 *rpcpool.Pool 都实现了该接口）
+type rpcCaller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// RPCTracer 基于 debug_traceBlockByNumber + callTracer 的追踪器
+// 节点需要开启 debug 命名空间（多数公共 RPC 不支持）
+type RPCTracer struct {
+	client rpcCaller
+}
+
+// NewRPCTracer 创建追踪器
+func NewRPCTracer(client rpcCaller) *RPCTracer {
+	return &RPCTracer{client: client}
+}
+
+// TraceBlock 追踪区块内所有交易的调用树
+func (t *RPCTracer) TraceBlock(ctx context.Context, number uint64) ([]TxTrace, error) {
+	var traces []TxTrace
+	err := t.client.CallContext(ctx, &traces, "debug_traceBlockByNumber",
+		hexutil.EncodeUint64(number),
+		map[string]interface{}{"tracer": "callTracer"},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("trace block %d: %w", number, err)
+	}
+	return traces, nil
+}
+
+// InternalTransferHandler 内部转账（合约发起的原生币转账）处理器
+// 覆盖多签、交易所热钱包合约、路由合约等转入的充值
+type InternalTransferHandler struct {
+	tracer     BlockTracer
+	watch      *AddressSet                                       // 监控的地址（可与其他处理器共享）
+	callback   func(ctx context.Context, deposit *Deposit) error // 充值回调
+	onRollback func(fromBlock uint64)                            // 链重组回调（可选）
+}
+
+// NewInternalTransferHandler 创建内部转账处理器
+func NewInternalTransferHandler(tracer BlockTracer, watch *AddressSet, callback func(context.Context, *Deposit) error) *InternalTransferHandler {
+	return &InternalTransferHandler{
+		tracer:   tracer,
+		watch:    watch,
+		callback: callback,
+	}
+}
+
+// AddWatchAddress 添加监控地址（并发安全，可在扫块过程中调用）
+func (h *InternalTransferHandler) AddWatchAddress(addr string) {
+	h.watch.Add(common.HexToAddress(addr))
+}
+
+// SetRollbackCallback 设置链重组回调，>= fromBlock 的充值需要冲正
+func (h *InternalTransferHandler) SetRollbackCallback(fn func(fromBlock uint64)) {
+	h.onRollback = fn
+}
+
+// HandleBlock 追踪整个区块并提取内部转账
+func (h *InternalTransferHandler) HandleBlock(ctx context.Context, block *types.Block) error {
+	traces, err := h.tracer.TraceBlock(ctx, block.NumberU64())
+	if err != nil {
+		return err
+	}
+
+	for _, deposit := range h.extract(block.NumberU64(), traces) {
+		log.Printf("检测到内部转账充值: from=%s, to=%s, value=%s ETH, tx=%s, traceIndex=%d",
+			deposit.From.Hex(),
+			deposit.To.Hex(),
+			weiToEth(deposit.Value),
+			deposit.TxHash.Hex(),
+			deposit.TraceIndex,
+		)
+
+		if h.callback != nil {
+			if err := h.callback(ctx, deposit); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WantsReceipt 内部转账来自调用追踪，不需要收据
+func (h *InternalTransferHandler) WantsReceipt(tx *types.Transaction) bool {
+	return false
+}
+
+// HandleTransaction 内部转账在区块级别处理
+func (h *InternalTransferHandler) HandleTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	return nil
+}
+
+// HandleRollback 处理链重组
+func (h *InternalTransferHandler) HandleRollback(ctx context.Context, fromBlock uint64) error {
+	log.Printf("链重组: 区块 %d 及之后的内部转账充值失效", fromBlock)
+	if h.onRollback != nil {
+		h.onRollback(fromBlock)
+	}
+	return nil
+}
+
+// extract 从调用树中提取发往监控地址的内部转账
+func (h *InternalTransferHandler) extract(blockNumber uint64, traces []TxTrace) []*Deposit {
+	var deposits []*Deposit
+
+	for _, trace := range traces {
+		// 顶层交易失败，所有内部转账都已回滚
+		if trace.Result == nil || trace.Result.Error != "" {
+			continue
+		}
+
+		index := uint(0)
+		var walk func(frame *CallFrame, depth int)
+		walk = func(frame *CallFrame, depth int) {
+			current := index
+			index++
+
+			// 失败的调用帧及其子调用都不会转移资金，但仍要占用序号保证稳定
+			if frame.Error != "" {
+				skipFrames(frame, &index)
+				return
+			}
+
+			// 顶层转账由 DepositHandler 处理
+			if depth > 0 && carriesValue(frame) && h.watch.Contains(frame.To) {
+				deposits = append(deposits, &Deposit{
+					TxHash:      trace.TxHash,
+					BlockNumber: blockNumber,
+					From:        frame.From,
+					To:          frame.To,
+					Value:       frame.Value.ToInt(),
+					Status:      types.ReceiptStatusSuccessful,
+					TraceIndex:  current,
+				})
+			}
+
+			for i := range frame.Calls {
+				walk(&frame.Calls[i], depth+1)
+			}
+		}
+		walk(trace.Result, 0)
+	}
+
+	return deposits
+}
+
+// carriesValue 调用帧是否真正转移了原生币
+// DELEGATECALL / CALLCODE 不会把钱转给 to，STATICCALL 不能带 value
+func carriesValue(frame *CallFrame) bool {
+	switch frame.Type {
+	case "CALL", "CREATE", "CREATE2", "SELFDESTRUCT":
+	default:
+		return false
+	}
+	return frame.Value != nil && frame.Value.ToInt().Cmp(big.NewInt(0)) > 0
+}
+
+// skipFrames 跳过子调用，只累加序号
+func skipFrames(frame *CallFrame, index *uint) {
+	for i := range frame.Calls {
+		*index++
+		skipFrames(&frame.Calls[i], index)
+	}
+}
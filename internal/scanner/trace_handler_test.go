@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fixtureTracer 从录制的 debug_traceBlockByNumber 结果读取调用树
+type fixtureTracer struct {
+	path string
+}
+
+func (t fixtureTracer) TraceBlock(ctx context.Context, number uint64) ([]TxTrace, error) {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return nil, err
+	}
+	var traces []TxTrace
+	if err := json.Unmarshal(data, &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
+
+func TestInternalTransferHandler(t *testing.T) {
+	watched := "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"
+
+	var deposits []*Deposit
+	h := NewInternalTransferHandler(
+		fixtureTracer{path: "testdata/trace_block.json"},
+		NewAddressSet(watched),
+		func(ctx context.Context, d *Deposit) error { deposits = append(deposits, d); return nil },
+	)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(19000000)})
+	if err := h.HandleBlock(context.Background(), block); err != nil {
+		t.Fatalf("HandleBlock: %v", err)
+	}
+
+	// 期望：多签内部转账 1 ETH、自毁转账 0.3 ETH
+	// 不期望：失败子调用、失败交易中的转账、发往非监控地址的转账
+	want := []struct {
+		tx         string
+		from       string
+		value      string
+		traceIndex uint
+	}{
+		{
+			tx:         "0x6a1d3c7a5f0e8b2d4c9e1f3a5b7c9d0e2f4a6b8c0d1e3f5a7b9c1d3e5f7a9b1c",
+			from:       "0x5afe3855358e112b5647b952709e6165e1c1eeee",
+			value:      "1000000000000000000",
+			traceIndex: 2,
+		},
+		{
+			tx:         "0x4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e",
+			from:       "0xbeefbeefbeefbeefbeefbeefbeefbeefbeefbeef",
+			value:      "300000000000000000",
+			traceIndex: 1,
+		},
+	}
+
+	if len(deposits) != len(want) {
+		t.Fatalf("got %d deposits, want %d", len(deposits), len(want))
+	}
+	for i, w := range want {
+		d := deposits[i]
+		if d.TxHash != common.HexToHash(w.tx) {
+			t.Errorf("deposit %d: tx = %s, want %s", i, d.TxHash.Hex(), w.tx)
+		}
+		if d.From != common.HexToAddress(w.from) {
+			t.Errorf("deposit %d: from = %s, want %s", i, d.From.Hex(), w.from)
+		}
+		if d.To != common.HexToAddress(watched) {
+			t.Errorf("deposit %d: to = %s, want %s", i, d.To.Hex(), watched)
+		}
+		if d.Value.String() != w.value {
+			t.Errorf("deposit %d: value = %s, want %s", i, d.Value, w.value)
+		}
+		if d.TraceIndex != w.traceIndex {
+			t.Errorf("deposit %d: traceIndex = %d, want %d", i, d.TraceIndex, w.traceIndex)
+		}
+		if d.BlockNumber != 19000000 {
+			t.Errorf("deposit %d: block = %d", i, d.BlockNumber)
+		}
+	}
+}
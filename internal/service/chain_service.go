@@ -0,0 +1,439 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"wallet/config"
+	"wallet/internal/metrics"
+	"wallet/internal/scanner"
+	"wallet/internal/transfer"
+	"wallet/pkg/rpcpool"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Stores 所有链共用的存储
+type Stores struct {
+	DB          *sql.DB // 只有用到 sql 存储时才会打开
+	Cursor      scanner.CursorStore
+	Deposits    scanner.DepositStore
+	DeadLetters scanner.DeadLetterStore
+	Outbound    transfer.TxStore
+}
+
+// OpenStores 按配置打开扫块游标、充值、死信和出账交易存储
+func OpenStores(ctx context.Context, cfg *config.Config) (*Stores, error) {
+	stores := &Stores{}
+	if cfg.Scanner.CursorStore == "sql" || cfg.Scanner.DepositStore == "sql" {
+		// 驱动见 drivers.go
+		if err := checkDriver(cfg.Database.Driver); err != nil {
+			return nil, err
+		}
+		db, err := sql.Open(cfg.Database.Driver, cfg.Database.DSN())
+		if err != nil {
+			return nil, fmt.Errorf("open database: %w", err)
+		}
+		stores.DB = db
+	}
+
+	var err error
+	if stores.Cursor, err = newCursorStore(ctx, cfg, stores.DB); err != nil {
+		stores.Close()
+		return nil, fmt.Errorf("create cursor store: %w", err)
+	}
+	if stores.Deposits, err = newDepositStore(ctx, cfg, stores.DB); err != nil {
+		stores.Close()
+		return nil, fmt.Errorf("create deposit store: %w", err)
+	}
+
+	if stores.DeadLetters, err = scanner.NewFileDeadLetterStore(DeadLetterFile(cfg)); err != nil {
+		stores.Close()
+		return nil, fmt.Errorf("create dead letter store: %w", err)
+	}
+
+	if stores.Outbound, err = OpenOutboundStore(cfg); err != nil {
+		stores.Close()
+		return nil, err
+	}
+	return stores, nil
+}
+
+// DeadLetterFile 死信文件路径
+func DeadLetterFile(cfg *config.Config) string {
+	if cfg.Scanner.DeadLetterFile == "" {
+		return "data/dead_letters.json"
+	}
+	return cfg.Scanner.DeadLetterFile
+}
+
+// BackfillDeadLetterFile 补扫使用的死信文件，例如 data/dead_letters.backfill.json
+// 补扫可以与 worker 同时运行（sql 充值存储），死信文件每次整体重写，不能两个进程共用
+func BackfillDeadLetterFile(cfg *config.Config) string {
+	path := DeadLetterFile(cfg)
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".backfill" + ext
+}
+
+// OpenOutboundStore 打开出账交易存储
+// 出账进程（cli withdraw）只需要这一个存储，与 worker 的出账跟踪共用同一个文件
+func OpenOutboundStore(cfg *config.Config) (transfer.TxStore, error) {
+	path := cfg.Transfer.TxStoreFile
+	if path == "" {
+		path = "data/outbound_txs.json"
+	}
+	store, err := transfer.NewFileTxStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("create outbound tx store: %w", err)
+	}
+	return store, nil
+}
+
+// Close 关闭数据库和文件
+func (s *Stores) Close() {
+	if closer, ok := s.Deposits.(interface{ Close() error }); ok {
+		closer.Close()
+	}
+	if s.DB != nil {
+		s.DB.Close()
+	}
+}
+
+// ChainService 单条链的后台服务：RPC 连接池、扫块器、充值处理器、确认跟踪器和出账交易跟踪
+type ChainService struct {
+	Chain     config.ChainConfig
+	Pool      *rpcpool.Pool
+	Scanner   *scanner.Scanner
+	Tracker   *scanner.DepositTracker
+	Addresses *scanner.AddressSet
+	Outbound  *transfer.Tracker
+
+	cfg    *config.Config
+	loader *scanner.SQLAddressLoader
+}
+
+// NewChainService 按配置组装一条链的扫块服务
+func NewChainService(ctx context.Context, cfg *config.Config, chain config.ChainConfig, stores *Stores) (*ChainService, error) {
+	if len(chain.RPCURLs) == 0 {
+		return nil, fmt.Errorf("chain %s: no rpc url", chain.Name)
+	}
+
+	// RPC 连接池（扫块、追踪共用）
+	pool, err := dialPool(ctx, cfg, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	// 监控地址集合，所有充值处理器共享
+	// 接入数据库时从 addresses 表全量加载，之后定期增量同步 API 新建的地址
+	addresses := scanner.NewAddressSet()
+	var loader *scanner.SQLAddressLoader
+	if stores.DB != nil {
+		loader = scanner.NewSQLAddressLoader(stores.DB, cfg.Database.Driver, chain.ChainID)
+		if err := loader.LoadAll(ctx, addresses); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("load addresses: %w", err)
+		}
+		log.Printf("已加载监控地址: %s %d 个", chain.Name, addresses.Len())
+	}
+
+	tokens := make([]scanner.Token, 0, len(chain.Tokens))
+	contracts := make([]common.Address, 0, len(chain.Tokens))
+	for _, t := range chain.Tokens {
+		token := scanner.Token{
+			Address:  common.HexToAddress(t.Address),
+			Symbol:   t.Symbol,
+			Decimals: t.Decimals,
+		}
+		tokens = append(tokens, token)
+		contracts = append(contracts, token.Address)
+	}
+
+	// 创建扫块器
+	scanCfg := scanner.Config{
+		Client:        pool,
+		StartBlock:    cfg.Scanner.StartBlock,
+		ConfirmBlocks: 0, // 在链头检测充值，确认数由 DepositTracker 跟踪
+		BatchSize:     cfg.Scanner.BatchSize,
+		Cursor:        stores.Cursor,
+		ReorgDepth:    cfg.Scanner.ReorgDepth,
+		Concurrency:   cfg.Scanner.FetchConcurrency,
+		WSURLs:        chain.WSURLs,
+		ErrorPolicy:   errorPolicy(cfg.Scanner.ErrorPolicy),
+		DeadLetters:   stores.DeadLetters,
+	}
+	logsMode := scanner.ScanMode(chain.ScanMode) == scanner.ModeLogs
+	if logsMode {
+		// 日志模式不检测重组，只扫已确认的区块
+		scanCfg.Mode = scanner.ModeLogs
+		scanCfg.ConfirmBlocks = cfg.Scanner.ConfirmBlocks
+		scanCfg.LogRange = cfg.Scanner.LogRange
+		scanCfg.LogFilter = scanner.LogFilter{Contracts: contracts, Watch: addresses}
+	}
+	s, err := scanner.New(scanCfg)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("create scanner: %w", err)
+	}
+
+	svc := &ChainService{
+		Chain:     chain,
+		Pool:      pool,
+		Scanner:   s,
+		Addresses: addresses,
+		cfg:       cfg,
+		loader:    loader,
+	}
+
+	svc.Tracker = scanner.NewDepositTracker(uint64(chain.ChainID), cfg.Scanner.ConfirmBlocks, stores.Deposits, svc.onDeposit)
+	if logsMode {
+		// 扫块器已经只扫到 链头 - ConfirmBlocks，跟踪器按实际链头计算确认数，检测到即已确认
+		svc.Tracker.SetScanLag(cfg.Scanner.ConfirmBlocks)
+	}
+	if err := svc.Tracker.Restore(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("restore deposits: %w", err)
+	}
+
+	// 添加充值处理器（日志模式只有代币转账会进入处理器）
+	if !logsMode {
+		depositHandler := scanner.NewDepositHandler(svc.Addresses, svc.Tracker.Track)
+		depositHandler.SetFailedCallback(svc.onFailedDeposit)
+		svc.addHandler(depositHandler)
+	}
+
+	// 代币充值处理器（只识别配置中的代币合约）
+	if len(tokens) > 0 {
+		tokenHandler := scanner.NewTokenDepositHandler(svc.Addresses, tokens, svc.Tracker.Track)
+		tokenHandler.SetFailedCallback(svc.onFailedDeposit)
+		svc.addHandler(tokenHandler)
+	}
+
+	// 内部转账处理器（多签、合约热钱包转入）
+	if chain.TraceInternal && logsMode {
+		log.Printf("链 %s 使用日志模式，忽略 trace_internal", chain.Name)
+	} else if chain.TraceInternal {
+		svc.addHandler(scanner.NewInternalTransferHandler(
+			scanner.NewRPCTracer(pool),
+			svc.Addresses,
+			svc.Tracker.Track,
+		))
+	}
+
+	// 确认跟踪器放在所有充值处理器之后
+	svc.addHandler(svc.Tracker)
+
+	// 出账交易跟踪（与出账进程共用同一份出账记录）
+	svc.Outbound = transfer.NewTracker(pool, stores.Outbound, uint64(chain.ChainID), transfer.TrackerConfig{
+		Interval:      cfg.Transfer.TrackInterval,
+		Confirmations: cfg.Transfer.Confirmations,
+	})
+
+	return svc, nil
+}
+
+// dialPool 按配置连接一条链的 RPC 节点
+func dialPool(ctx context.Context, cfg *config.Config, chain config.ChainConfig) (*rpcpool.Pool, error) {
+	return rpcpool.Dial(ctx, chain.RPCURLs, rpcpool.Config{
+		Strategy:            rpcpool.Strategy(cfg.RPC.Strategy),
+		MaxLag:              cfg.RPC.MaxLag,
+		MaxFailures:         cfg.RPC.MaxFailures,
+		EjectDuration:       cfg.RPC.EjectDuration,
+		HealthCheckInterval: cfg.RPC.HealthCheckInterval,
+		OnError:             metrics.RPCErrorObserver(strconv.FormatInt(chain.ChainID, 10)),
+	})
+}
+
+// Run 启动地址同步、出账交易跟踪和扫块，直到 ctx 取消或处理器按 halt 策略停止
+func (c *ChainService) Run(ctx context.Context) error {
+	if c.loader != nil {
+		go c.loader.Run(ctx, c.Addresses, c.cfg.Scanner.AddressSyncInterval)
+	}
+	go c.Outbound.Run(ctx)
+	return c.Scanner.Start(ctx, c.cfg.Scanner.ScanInterval)
+}
+
+// ReplayDeadLetters 重放本链的死信
+// 先把确认跟踪器推进到链头，重放出的充值才能算出正确的确认数；
+// 未达到确认数的充值写入充值存储，worker 重启后继续跟踪
+func (c *ChainService) ReplayDeadLetters(ctx context.Context, store scanner.DeadLetterStore) (*scanner.ReplayResult, error) {
+	if _, err := c.syncTracker(ctx); err != nil {
+		return nil, err
+	}
+	return c.Scanner.ReplayDeadLetters(ctx, store)
+}
+
+// Backfill 补扫历史区间 [from, to]，不影响 worker 的扫块游标
+// names 为空时使用所有充值处理器；确认跟踪器不参与区间扫描，只负责计算确认数。
+// 补扫结束后没有进程继续推进确认数，因此 to 限制在已达到确认数的区块内，
+// 返回实际补扫到的区块
+func (c *ChainService) Backfill(ctx context.Context, from, to uint64, names []string) (uint64, error) {
+	all := make(map[string]scanner.Handler)
+	for _, h := range c.Scanner.Handlers() {
+		if h != scanner.Handler(c.Tracker) {
+			all[scanner.HandlerName(h)] = h
+		}
+	}
+
+	var handlers []scanner.Handler
+	if len(names) == 0 {
+		for _, h := range c.Scanner.Handlers() {
+			if _, ok := all[scanner.HandlerName(h)]; ok {
+				handlers = append(handlers, h)
+			}
+		}
+	}
+	for _, name := range names {
+		h, ok := all[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown handler: %s", name)
+		}
+		handlers = append(handlers, h)
+	}
+
+	head, err := c.syncTracker(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if confirmed := confirmedBlock(head, c.cfg.Scanner.ConfirmBlocks); to > confirmed {
+		to = confirmed
+	}
+	if from > to {
+		return 0, fmt.Errorf("blocks from %d are not confirmed yet (head %d, %d confirmations)",
+			from, head, c.cfg.Scanner.ConfirmBlocks)
+	}
+	return to, c.Scanner.ScanRange(ctx, from, to, handlers...)
+}
+
+// confirmedBlock 链头为 head 时已达到确认数的最高区块
+func confirmedBlock(head, confirmations uint64) uint64 {
+	if confirmations <= 1 {
+		return head
+	}
+	if head+1 < confirmations {
+		return 0
+	}
+	return head + 1 - confirmations
+}
+
+// syncTracker 把确认跟踪器推进到链头，返回链头高度
+// 补扫和重放出的历史充值才能算出正确的确认数
+func (c *ChainService) syncTracker(ctx context.Context) (uint64, error) {
+	header, err := c.Pool.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("get latest header: %w", err)
+	}
+	return header.Number.Uint64(), c.Tracker.HandleBlock(ctx, types.NewBlockWithHeader(header))
+}
+
+// Close 关闭 RPC 连接
+func (c *ChainService) Close() {
+	c.Pool.Close()
+}
+
+// addHandler 添加处理器，配置了单独错误策略的一并设置
+func (c *ChainService) addHandler(h scanner.Handler) {
+	c.Scanner.AddHandler(h)
+	if policy, ok := c.cfg.Scanner.HandlerPolicies[scanner.HandlerName(h)]; ok {
+		c.Scanner.SetErrorPolicy(h, errorPolicy(policy))
+	}
+}
+
+// onDeposit 充值状态变化（pending → confirm_count 递增 → confirmed / orphaned）
+func (c *ChainService) onDeposit(deposit *scanner.Deposit) {
+	amount := weiToEth(deposit.Value) + " ETH"
+	if deposit.IsToken() {
+		amount = deposit.Value.String() + " (token " + deposit.TokenAddress.Hex() + ")"
+	}
+	log.Printf("💰 充值[%s %d/%d]: from=%s, to=%s, amount=%s, tx=%s",
+		deposit.State,
+		deposit.ConfirmCount,
+		c.cfg.Scanner.ConfirmBlocks,
+		deposit.From.Hex(),
+		deposit.To.Hex(),
+		amount,
+		deposit.TxHash.Hex(),
+	)
+	// TODO: 按状态更新 deposits 表、confirmed 时入账并通知用户
+}
+
+// onFailedDeposit 失败交易不入账，只记录供客服查询
+func (c *ChainService) onFailedDeposit(deposit *scanner.Deposit) {
+	amount := weiToEth(deposit.Value) + " ETH"
+	if deposit.IsToken() {
+		amount = deposit.Value.String() + " (token " + deposit.TokenAddress.Hex() + ")"
+	}
+	log.Printf("⚠️ 充值交易失败: chain=%s, from=%s, to=%s, amount=%s, tx=%s",
+		c.Chain.Name,
+		deposit.From.Hex(),
+		deposit.To.Hex(),
+		amount,
+		deposit.TxHash.Hex(),
+	)
+	// TODO: 写入失败充值记录表
+}
+
+// errorPolicy 配置转换为扫块器错误策略
+func errorPolicy(c config.ErrorPolicyConfig) scanner.ErrorPolicy {
+	return scanner.ErrorPolicy{
+		Retries:   c.Retries,
+		Backoff:   c.Backoff,
+		OnFailure: scanner.FailureAction(c.OnFailure),
+	}
+}
+
+// newCursorStore 按配置创建扫块游标存储
+func newCursorStore(ctx context.Context, cfg *config.Config, db *sql.DB) (scanner.CursorStore, error) {
+	switch cfg.Scanner.CursorStore {
+	case "":
+		return nil, nil
+	case "file":
+		dir := cfg.Scanner.CursorDir
+		if dir == "" {
+			dir = "data/cursor"
+		}
+		return scanner.NewFileCursorStore(dir)
+	case "sql":
+		store := scanner.NewSQLCursorStore(db, cfg.Database.Driver)
+		if err := store.Init(ctx); err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown cursor store: %s", cfg.Scanner.CursorStore)
+	}
+}
+
+// newDepositStore 按配置创建充值存储（幂等去重）
+func newDepositStore(ctx context.Context, cfg *config.Config, db *sql.DB) (scanner.DepositStore, error) {
+	switch cfg.Scanner.DepositStore {
+	case "", "memory":
+		return scanner.NewMemoryDepositStore(), nil
+	case "file":
+		path := cfg.Scanner.DepositFile
+		if path == "" {
+			path = "data/deposits.jsonl"
+		}
+		return scanner.NewFileDepositStore(path)
+	case "sql":
+		store := scanner.NewSQLDepositStore(db, cfg.Database.Driver)
+		if err := store.Init(ctx); err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown deposit store: %s", cfg.Scanner.DepositStore)
+	}
+}
+
+func weiToEth(wei interface{}) string {
+	// 简化版本，实际应该使用 big.Int
+
+	return "0.00"
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+
+	_ "github.com/go-sql-driver/mysql" // database.driver: mysql
+	_ "github.com/lib/pq"              // database.driver: postgres
+)
+
+// checkDriver 确认配置的数据库驱动已编译进程序
+// sql.Open 只在第一次查询时才会暴露驱动缺失，这里提前报错
+func checkDriver(driver string) error {
+	if !slices.Contains(sql.Drivers(), driver) {
+		return fmt.Errorf("database driver %q is not supported (available: %v)", driver, sql.Drivers())
+	}
+	return nil
+}
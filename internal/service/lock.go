@@ -0,0 +1,52 @@
+//go:build unix
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"wallet/config"
+)
+
+// ErrWorkerRunning worker 进程锁已被其他进程持有
+var ErrWorkerRunning = errors.New("worker is running")
+
+// WorkerLock worker 运行期间持有的文件锁（flock，进程退出后自动释放）
+// 文件存储只能由一个进程写入，cli 的补扫、重放在写文件前先获取这把锁
+type WorkerLock struct {
+	file *os.File
+}
+
+// LockWorker 获取 worker 进程锁，已被持有时返回 ErrWorkerRunning
+func LockWorker(cfg *config.Config) (*WorkerLock, error) {
+	path := cfg.Scanner.LockFile
+	if path == "" {
+		path = "data/worker.lock"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create lock dir: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w (lock %s)", ErrWorkerRunning, path)
+		}
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return &WorkerLock{file: file}, nil
+}
+
+// Unlock 释放进程锁
+func (l *WorkerLock) Unlock() {
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package service
+
+import (
+	"errors"
+
+	"wallet/config"
+)
+
+// ErrWorkerRunning worker 进程锁已被其他进程持有
+var ErrWorkerRunning = errors.New("worker is running")
+
+// WorkerLock 非 unix 平台不支持 flock，不做进程互斥
+type WorkerLock struct{}
+
+// LockWorker 非 unix 平台总是成功
+func LockWorker(cfg *config.Config) (*WorkerLock, error) {
+	return &WorkerLock{}, nil
+}
+
+// Unlock 释放进程锁
+func (l *WorkerLock) Unlock() {}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"wallet/config"
+	"wallet/internal/transfer"
+	"wallet/pkg/rpcpool"
+)
+
+// TransferService 单条链的出账服务：转账管理器及其 nonce、出账记录持久化
+// nonce 文件分配时加跨进程文件锁，多个出账进程可以共用同一热钱包；
+// 出账记录与 worker 共用，worker 的 Tracker 负责中断后的重新广播和确认
+type TransferService struct {
+	Chain    config.ChainConfig
+	Pool     *rpcpool.Pool
+	Transfer *transfer.Transfer
+}
+
+// NewTransferService 按配置组装一条链的出账服务，txs 见 OpenOutboundStore
+func NewTransferService(ctx context.Context, cfg *config.Config, chain config.ChainConfig, txs transfer.TxStore) (*TransferService, error) {
+	if len(chain.RPCURLs) == 0 {
+		return nil, fmt.Errorf("chain %s: no rpc url", chain.Name)
+	}
+
+	path := cfg.Transfer.NonceFile
+	if path == "" {
+		path = "data/nonces.json"
+	}
+	nonceStore, err := transfer.NewFileNonceStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("create nonce store: %w", err)
+	}
+
+	pool, err := dialPool(ctx, cfg, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := transfer.NewWithClient(pool)
+	tr.SetNonceManager(transfer.NewNonceManager(pool, uint64(chain.ChainID), nonceStore))
+	tr.SetWaitConfig(transfer.WaitConfig{Confirmations: cfg.Transfer.Confirmations})
+	tr.SetTxStore(txs)
+
+	return &TransferService{Chain: chain, Pool: pool, Transfer: tr}, nil
+}
+
+// Close 关闭 RPC 连接
+func (s *TransferService) Close() {
+	s.Pool.Close()
+}
@@ -0,0 +1,98 @@
+package transfer
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// assemble 编译 testdata 中的 EVM 汇编，返回合约创建代码
+//
+// 汇编语法（每个词之间用空白分隔）：
+//
+//	; 注释到行尾
+//	name:        标签，生成 JUMPDEST
+//	@name        PUSH2 标签位置
+//	PUSH 0x20    按数值大小选择 PUSH1 ~ PUSH32
+//	PUSH4 0x..   指定宽度
+//	ADD          其他操作码
+func assemble(t *testing.T, path string) []byte {
+	t.Helper()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []string
+	for _, line := range strings.Split(string(src), "\n") {
+		line, _, _ = strings.Cut(line, ";")
+		words = append(words, strings.Fields(line)...)
+	}
+
+	// 第一遍确定标签位置，第二遍生成代码
+	labels := make(map[string]int)
+	var code []byte
+	for pass := 0; pass < 2; pass++ {
+		code = code[:0]
+		for i := 0; i < len(words); i++ {
+			w := words[i]
+			switch {
+			case strings.HasSuffix(w, ":"):
+				labels[strings.TrimSuffix(w, ":")] = len(code)
+				code = append(code, byte(vm.JUMPDEST))
+			case strings.HasPrefix(w, "@"):
+				pc, ok := labels[w[1:]]
+				if !ok && pass == 1 {
+					t.Fatalf("%s: 未定义的标签 %s", path, w)
+				}
+				code = append(code, byte(vm.PUSH2), byte(pc>>8), byte(pc))
+			case strings.HasPrefix(w, "PUSH") && w != "PUSH0":
+				i++
+				if i == len(words) {
+					t.Fatalf("%s: %s 缺少参数", path, w)
+				}
+				code = append(code, push(t, w, words[i])...)
+			default:
+				op := vm.StringToOp(w)
+				if op.String() != w {
+					t.Fatalf("%s: 未知操作码 %s", path, w)
+				}
+				code = append(code, byte(op))
+			}
+		}
+	}
+
+	// 创建代码：把后面的运行时代码复制到内存并返回
+	size := len(code)
+	init := []byte{
+		byte(vm.PUSH2), byte(size >> 8), byte(size),
+		byte(vm.DUP1),
+		byte(vm.PUSH2), 0, 13,
+		byte(vm.PUSH1), 0,
+		byte(vm.CODECOPY),
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}
+	return append(init, code...)
+}
+
+// push 编码 PUSH 指令，PUSH 按数值选择最小宽度
+func push(t *testing.T, op, arg string) []byte {
+	t.Helper()
+	v, ok := new(big.Int).SetString(arg, 0)
+	if !ok || v.Sign() < 0 {
+		t.Fatalf("无效的 PUSH 参数 %s", arg)
+	}
+	width := len(v.Bytes())
+	if op != "PUSH" {
+		if _, err := fmt.Sscanf(op, "PUSH%d", &width); err != nil || width < len(v.Bytes()) {
+			t.Fatalf("%s %s: 宽度不对", op, arg)
+		}
+	}
+	width = max(width, 1)
+	return append([]byte{byte(vm.PUSH1) + byte(width-1)}, common.LeftPadBytes(v.Bytes(), width)...)
+}
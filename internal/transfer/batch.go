@@ -0,0 +1,298 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"wallet/pkg/gas"
+	"wallet/pkg/signer"
+)
+
+// disperseABI Disperse 合约（disperse.app）接口
+// 多数 EVM 链上已部署在 0xD152f549545093347A162Dce210e7293f1452150
+const disperseABI = `[
+	{"type":"function","name":"disperseEther","stateMutability":"payable","inputs":[{"name":"recipients","type":"address[]"},{"name":"values","type":"uint256[]"}],"outputs":[]},
+	{"type":"function","name":"disperseToken","stateMutability":"nonpayable","inputs":[{"name":"token","type":"address"},{"name":"recipients","type":"address[]"},{"name":"values","type":"uint256[]"}],"outputs":[]}
+]`
+
+var disperse = mustParseABI(disperseABI)
+
+// Payout 批量发放中的一笔
+type Payout struct {
+	To     common.Address
+	Amount *big.Int        // 最小单位
+	Token  *common.Address // ERC-20 代币合约，nil 表示原生币
+}
+
+// BatchRequest 批量发放请求
+type BatchRequest struct {
+	ID      string // 批次号，出账记录 ID 由批次号派生；为空时自动生成
+	From    common.Address
+	Signer  signer.Signer
+	Payouts []Payout
+	Speed   gas.Speed
+}
+
+// PayoutResult 单笔发放结果
+// 通过 Disperse 合约发放时，同一币种的发放共用一笔交易（Result 相同）
+type PayoutResult struct {
+	Payout Payout
+	Result *Result
+	Err    error
+}
+
+// payoutGroup 同一币种的发放
+type payoutGroup struct {
+	token   *common.Address
+	indexes []int // 在 BatchRequest.Payouts 中的位置
+	to      []common.Address
+	amounts []*big.Int
+	total   *big.Int
+}
+
+// SetDisperse 设置 Disperse 合约地址，设置后批量发放按币种合并为一笔合约调用
+func (t *Transfer) SetDisperse(addr common.Address) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disperse = &addr
+}
+
+// ExecuteBatch 批量发放
+//
+// 配置了 Disperse 合约且链上存在该合约时，原生币合并为一笔 disperseEther，
+// 每种代币先确保授权额度再合并为一笔 disperseToken；否则逐笔依次转账，
+// 上一笔确认后再发下一笔。发送前检查余额是否够付发放总额和全部 gas 费用。
+// 返回的错误只表示发送前的检查失败，单笔结果见 PayoutResult.Err。
+func (t *Transfer) ExecuteBatch(ctx context.Context, req BatchRequest) ([]PayoutResult, error) {
+	if len(req.Payouts) == 0 {
+		return nil, fmt.Errorf("发放列表为空")
+	}
+	if req.ID == "" {
+		req.ID = newTxID()
+	}
+
+	groups, err := groupPayouts(req.Payouts)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkBatchBalance(ctx, req, groups); err != nil {
+		return nil, err
+	}
+
+	results := make([]PayoutResult, len(req.Payouts))
+	for i, p := range req.Payouts {
+		results[i].Payout = p
+	}
+
+	contract, err := t.disperseContract(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if contract == nil {
+		t.executeEach(ctx, req, results)
+		return results, nil
+	}
+
+	for _, g := range groups {
+		result, err := t.disperseGroup(ctx, req, *contract, g)
+		if err == nil && !result.Success {
+			err = fmt.Errorf("批量发放交易执行失败: %s", result.TxHash.Hex())
+		}
+		for _, i := range g.indexes {
+			results[i].Result, results[i].Err = result, err
+		}
+	}
+	return results, nil
+}
+
+// groupPayouts 按币种分组（保持首次出现的顺序）
+func groupPayouts(payouts []Payout) ([]*payoutGroup, error) {
+	var groups []*payoutGroup
+	byToken := make(map[common.Address]*payoutGroup)
+	for i, p := range payouts {
+		if p.Amount == nil || p.Amount.Sign() <= 0 {
+			return nil, fmt.Errorf("第 %d 笔发放金额无效", i+1)
+		}
+
+		var key common.Address // 原生币用零地址
+		if p.Token != nil {
+			key = *p.Token
+		}
+		g, ok := byToken[key]
+		if !ok {
+			g = &payoutGroup{token: p.Token, total: new(big.Int)}
+			byToken[key] = g
+			groups = append(groups, g)
+		}
+		g.indexes = append(g.indexes, i)
+		g.to = append(g.to, p.To)
+		g.amounts = append(g.amounts, p.Amount)
+		g.total.Add(g.total, p.Amount)
+	}
+	return groups, nil
+}
+
+// checkBatchBalance 各币种余额是否够发放总额，原生币余额还要够付全部交易的最高 gas 费用
+// gas 按逐笔转账估算（每个币种用第一笔的估算乘以笔数），Disperse 合并发送时实际更低
+func (t *Transfer) checkBatchBalance(ctx context.Context, req BatchRequest, groups []*payoutGroup) error {
+	native := new(big.Int)
+	for _, g := range groups {
+		if g.token == nil {
+			native.Add(native, g.total)
+			continue
+		}
+		balance, err := t.TokenBalance(ctx, *g.token, req.From)
+		if err != nil {
+			return fmt.Errorf("获取代币余额失败: %w", err)
+		}
+		if balance.Cmp(g.total) < 0 {
+			return fmt.Errorf("代币 %s 余额不足: 需要 %s, 当前 %s", g.token.Hex(), g.total, balance)
+		}
+	}
+
+	balance, err := t.client.BalanceAt(ctx, req.From, nil)
+	if err != nil {
+		return fmt.Errorf("获取余额失败: %w", err)
+	}
+	if balance.Cmp(native) < 0 {
+		return fmt.Errorf("余额不足: 需要 %s, 当前 %s", weiToEth(native), weiToEth(balance))
+	}
+
+	fee := new(big.Int)
+	for _, g := range groups {
+		first := Request{To: g.to[0], Token: g.token, Amount: g.amounts[0]}
+		call, err := first.call()
+		if err != nil {
+			return err
+		}
+		params, err := gas.SuggestGasParams(ctx, t.client, req.From, &call.to, call.value, call.data, req.Speed)
+		if err != nil {
+			return fmt.Errorf("估算 gas 失败: %w", err)
+		}
+		fee.Add(fee, new(big.Int).Mul(maxGasCost(params), big.NewInt(int64(len(g.to)))))
+	}
+	need := new(big.Int).Add(native, fee)
+	if balance.Cmp(need) < 0 {
+		return fmt.Errorf("余额不足: 需要 %s（发放 %s + 最高 gas 费 %s）, 当前 %s",
+			weiToEth(need), weiToEth(native), weiToEth(fee), weiToEth(balance))
+	}
+	return nil
+}
+
+// disperseContract 可用的 Disperse 合约地址，未配置或链上没有合约时返回 nil
+func (t *Transfer) disperseContract(ctx context.Context) (*common.Address, error) {
+	t.mu.Lock()
+	addr := t.disperse
+	t.mu.Unlock()
+	if addr == nil {
+		return nil, nil
+	}
+
+	code, err := t.client.CodeAt(ctx, *addr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Disperse 合约失败: %w", err)
+	}
+	if len(code) == 0 {
+		log.Printf("链上没有 Disperse 合约 %s，改为逐笔发送", addr.Hex())
+		return nil, nil
+	}
+	return addr, nil
+}
+
+// disperseGroup 通过 Disperse 合约发放同一币种
+func (t *Transfer) disperseGroup(ctx context.Context, req BatchRequest, contract common.Address, g *payoutGroup) (*Result, error) {
+	if g.token == nil {
+		data, err := disperse.Pack("disperseEther", g.to, g.amounts)
+		if err != nil {
+			return nil, fmt.Errorf("编码 disperseEther 失败: %w", err)
+		}
+		return t.Execute(ctx, Request{
+			ID:     req.ID + "-native",
+			From:   req.From,
+			Signer: req.Signer,
+			To:     contract,
+			Amount: g.total,
+			Data:   data,
+			Speed:  req.Speed,
+		})
+	}
+
+	// 合约通过 transferFrom 转出，额度不足时先授权
+	// USDT 等代币不允许直接修改非 0 额度，先改为 0
+	allowance, err := t.TokenAllowance(ctx, *g.token, req.From, contract)
+	if err != nil {
+		return nil, fmt.Errorf("获取授权额度失败: %w", err)
+	}
+	if allowance.Cmp(g.total) < 0 {
+		if allowance.Sign() > 0 {
+			if err := t.approve(ctx, req, *g.token, contract, new(big.Int)); err != nil {
+				return nil, err
+			}
+		}
+		if err := t.approve(ctx, req, *g.token, contract, g.total); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := disperse.Pack("disperseToken", *g.token, g.to, g.amounts)
+	if err != nil {
+		return nil, fmt.Errorf("编码 disperseToken 失败: %w", err)
+	}
+	return t.Execute(ctx, Request{
+		ID:     req.ID + "-" + g.token.Hex(),
+		From:   req.From,
+		Signer: req.Signer,
+		To:     contract,
+		Amount: new(big.Int),
+		Data:   data,
+		Speed:  req.Speed,
+	})
+}
+
+// approve 授权 spender 使用 amount 额度
+// 授权不是出账，重试批次时会重新检查额度，出账记录 ID 加随机后缀，上次失败的记录不影响重试
+func (t *Transfer) approve(ctx context.Context, req BatchRequest, token, spender common.Address, amount *big.Int) error {
+	data, err := erc20.Pack("approve", spender, amount)
+	if err != nil {
+		return fmt.Errorf("编码 approve 失败: %w", err)
+	}
+	result, err := t.Execute(ctx, Request{
+		ID:     fmt.Sprintf("%s-approve-%s-%s", req.ID, token.Hex(), newTxID()[:8]),
+		From:   req.From,
+		Signer: req.Signer,
+		To:     token,
+		Amount: new(big.Int),
+		Data:   data,
+		Speed:  req.Speed,
+	})
+	if err != nil {
+		return fmt.Errorf("授权失败: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("授权交易执行失败: %s", result.TxHash.Hex())
+	}
+	return nil
+}
+
+// executeEach 逐笔依次转账，上一笔确认后再发下一笔
+// 前一笔失败不影响后面的发放，结果分别记录
+func (t *Transfer) executeEach(ctx context.Context, req BatchRequest, results []PayoutResult) {
+	for i, p := range req.Payouts {
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Result, results[i].Err = t.Execute(ctx, Request{
+			ID:     fmt.Sprintf("%s-%d", req.ID, i),
+			From:   req.From,
+			Signer: req.Signer,
+			To:     p.To,
+			Token:  p.Token,
+			Amount: p.Amount,
+			Speed:  req.Speed,
+		})
+	}
+}
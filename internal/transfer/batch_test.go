@@ -0,0 +1,257 @@
+package transfer
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newBatch 在模拟链上创建批量发放的请求模板
+func newBatch(t *testing.T) (*Transfer, BatchRequest) {
+	tr, sender := newSimulated(t)
+	return tr, BatchRequest{From: sender.From, Signer: sender.Signer}
+}
+
+// deploy 部署 testdata 中的合约（见 assemble）
+func deploy(t *testing.T, tr *Transfer, req BatchRequest, source string) common.Address {
+	ctx := context.Background()
+	client := tr.client
+	chainID, _ := client.ChainID(ctx)
+	nonce, _ := client.PendingNonceAt(ctx, req.From)
+	head, _ := client.HeaderByNumber(ctx, nil)
+
+	tx, err := req.Signer.SignTx(ctx, types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), big.NewInt(params.GWei)),
+		Gas:       500000,
+		Data:      assemble(t, source),
+	}), chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := NewWaiter(client, WaitConfig{PollInterval: 50 * time.Millisecond}).Wait(ctx, tx)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("部署 %s 失败: %v", source, err)
+	}
+	return receipt.ContractAddress
+}
+
+// deployDisperse 部署 Disperse 合约
+func deployDisperse(t *testing.T, tr *Transfer, req BatchRequest) common.Address {
+	return deploy(t, tr, req, "testdata/disperse.evm")
+}
+
+// deployToken 部署模拟代币并给发放地址铸造 amount
+func deployToken(t *testing.T, tr *Transfer, req BatchRequest, amount *big.Int) common.Address {
+	token := deploy(t, tr, req, "testdata/token.evm")
+	data := append(common.FromHex("40c10f19"), common.LeftPadBytes(req.From.Bytes(), 32)...) // mint(address,uint256)
+	data = append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+	callContract(t, tr, req, token, data)
+	return token
+}
+
+// callContract 调用合约，执行失败时终止测试
+func callContract(t *testing.T, tr *Transfer, req BatchRequest, to common.Address, data []byte) {
+	t.Helper()
+	result, err := tr.Execute(context.Background(), Request{
+		From:   req.From,
+		Signer: req.Signer,
+		To:     to,
+		Amount: new(big.Int),
+		Data:   data,
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("调用 %s 失败: %v", to.Hex(), err)
+	}
+}
+
+func testPayouts() []Payout {
+	var payouts []Payout
+	for i := 1; i <= 3; i++ {
+		payouts = append(payouts, Payout{
+			To:     common.BigToAddress(big.NewInt(int64(0x1000 + i))),
+			Amount: new(big.Int).Mul(big.NewInt(int64(i)), big.NewInt(params.Ether)),
+		})
+	}
+	return payouts
+}
+
+func checkPayouts(t *testing.T, tr *Transfer, results []PayoutResult) {
+	t.Helper()
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("发放到 %s 失败: %v", r.Payout.To.Hex(), r.Err)
+		}
+		balance, err := tr.client.BalanceAt(context.Background(), r.Payout.To, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(r.Payout.Amount) != 0 {
+			t.Errorf("%s 余额 = %s, want %s", r.Payout.To.Hex(), balance, r.Payout.Amount)
+		}
+	}
+}
+
+func TestExecuteBatchDisperse(t *testing.T) {
+	tr, req := newBatch(t)
+	tr.SetDisperse(deployDisperse(t, tr, req))
+
+	req.ID = "batch-1"
+	req.Payouts = testPayouts()
+	results, err := tr.ExecuteBatch(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPayouts(t, tr, results)
+
+	// 合并为一笔交易
+	for _, r := range results[1:] {
+		if r.Result.TxHash != results[0].Result.TxHash {
+			t.Errorf("原生币发放应共用一笔交易")
+		}
+	}
+}
+
+func TestExecuteBatchFallback(t *testing.T) {
+	tr, req := newBatch(t)
+	tr.SetDisperse(common.HexToAddress("0xD152f549545093347A162Dce210e7293f1452150")) // 链上没有代码
+
+	req.ID = "batch-2"
+	req.Payouts = testPayouts()
+	results, err := tr.ExecuteBatch(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPayouts(t, tr, results)
+
+	seen := make(map[common.Hash]bool)
+	for _, r := range results {
+		seen[r.Result.TxHash] = true
+	}
+	if len(seen) != len(results) {
+		t.Errorf("逐笔发送应各自一笔交易, got %d", len(seen))
+	}
+}
+
+// tokenPayouts 每个地址发放 i 个代币（6 位精度）
+func tokenPayouts(token common.Address) []Payout {
+	var payouts []Payout
+	for i := 1; i <= 3; i++ {
+		payouts = append(payouts, Payout{
+			To:     common.BigToAddress(big.NewInt(int64(0x2000 + i))),
+			Amount: big.NewInt(int64(i) * 1e6),
+			Token:  &token,
+		})
+	}
+	return payouts
+}
+
+func checkTokenPayouts(t *testing.T, tr *Transfer, token common.Address, results []PayoutResult) {
+	t.Helper()
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("发放到 %s 失败: %v", r.Payout.To.Hex(), r.Err)
+		}
+		balance, err := tr.TokenBalance(context.Background(), token, r.Payout.To)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(r.Payout.Amount) != 0 {
+			t.Errorf("%s 代币余额 = %s, want %s", r.Payout.To.Hex(), balance, r.Payout.Amount)
+		}
+	}
+}
+
+func TestExecuteBatchToken(t *testing.T) {
+	tr, req := newBatch(t)
+	store, err := NewFileTxStore(filepath.Join(t.TempDir(), "outbound.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetTxStore(store)
+	token := deployToken(t, tr, req, big.NewInt(100e6))
+	contract := deployDisperse(t, tr, req)
+	tr.SetDisperse(contract)
+
+	// 已有不够的非 0 额度：代币按 USDT 规则拒绝直接修改，需要先改为 0
+	approve, _ := erc20.Pack("approve", contract, big.NewInt(1))
+	callContract(t, tr, req, token, approve)
+
+	req.ID = "batch-token"
+	req.Payouts = tokenPayouts(token)
+	results, err := tr.ExecuteBatch(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTokenPayouts(t, tr, token, results)
+	for _, r := range results[1:] {
+		if r.Result.TxHash != results[0].Result.TxHash {
+			t.Errorf("同一代币的发放应共用一笔交易")
+		}
+	}
+	if allowance, _ := tr.TokenAllowance(context.Background(), token, req.From, contract); allowance.Sign() != 0 {
+		t.Errorf("剩余授权额度 %s", allowance)
+	}
+
+	// 同一批次号重试：授权不受上次的记录影响，发放本身因出账记录已存在而拒绝
+	if results, err = tr.ExecuteBatch(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if err := results[0].Err; err == nil || strings.Contains(err.Error(), "授权") || !strings.Contains(err.Error(), "已存在") {
+		t.Errorf("retry: %v", err)
+	}
+}
+
+func TestExecuteBatchTokenFallback(t *testing.T) {
+	tr, req := newBatch(t)
+	token := deployToken(t, tr, req, big.NewInt(100e6))
+
+	req.ID = "batch-token-each"
+	req.Payouts = tokenPayouts(token)
+	results, err := tr.ExecuteBatch(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTokenPayouts(t, tr, token, results)
+
+	// 逐笔依次发送：上一笔确认后才发下一笔
+	for i := 1; i < len(results); i++ {
+		if results[i].Result.BlockNumber <= results[i-1].Result.BlockNumber {
+			t.Errorf("第 %d 笔与上一笔在同一区块，不是依次发送", i+1)
+		}
+	}
+}
+
+func TestExecuteBatchGasCheck(t *testing.T) {
+	tr, req := newBatch(t)
+	balance, _ := tr.client.BalanceAt(context.Background(), req.From, nil)
+
+	// 发放总额刚好等于余额，不够付 gas，发送前就应拒绝
+	payouts := testPayouts()
+	sum := new(big.Int)
+	for _, p := range payouts[:len(payouts)-1] {
+		sum.Add(sum, p.Amount)
+	}
+	payouts[len(payouts)-1].Amount = new(big.Int).Sub(balance, sum)
+
+	req.ID = "batch-gas"
+	req.Payouts = payouts
+	if _, err := tr.ExecuteBatch(context.Background(), req); err == nil || !strings.Contains(err.Error(), "gas") {
+		t.Fatalf("want gas error, got %v", err)
+	}
+	if nonce, _ := tr.client.PendingNonceAt(context.Background(), req.From); nonce != 0 {
+		t.Errorf("发送前检查失败时不应发出交易, nonce=%d", nonce)
+	}
+}
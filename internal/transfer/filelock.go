@@ -0,0 +1,26 @@
+//go:build unix
+
+package transfer
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile 获取跨进程的排他文件锁（flock，阻塞等待），返回解锁函数
+// 出账文件由 cli 出账进程和 worker 的 Tracker 共同读写
+func lockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开锁文件失败: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("获取文件锁失败: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
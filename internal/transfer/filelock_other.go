@@ -0,0 +1,8 @@
+//go:build !unix
+
+package transfer
+
+// lockFile 非 unix 平台不支持 flock，只靠进程内的互斥锁
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,388 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// nonceSyncInterval 分配 nonce 时距上次与链上同步超过该时间则重新同步（检测空洞）
+const nonceSyncInterval = 30 * time.Second
+
+// nonceGapSyncs 连续多少次同步都看到同一个空洞才重新分配该 nonce
+// 单次 PendingNonceAt 可能来自交易池不完整的节点（负载均衡、刚重启），不能据此复用
+const nonceGapSyncs = 3
+
+// NonceClient 查询链上 nonce
+type NonceClient interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// NonceStore nonce 持久化
+// 保存的是下一个可分配的 nonce，重启后不会重复分配已经广播过的 nonce
+type NonceStore interface {
+	Load(ctx context.Context, chainID uint64, addr common.Address) (uint64, bool, error)
+	Save(ctx context.Context, chainID uint64, addr common.Address, next uint64) error
+}
+
+// LockingNonceStore 多个进程共用的 nonce 存储（例如 cli 出账和 worker 用同一个热钱包）
+// NonceManager 在 Lock 返回的锁内重新 Load 并 Save，两个进程不会分配到同一个 nonce
+type LockingNonceStore interface {
+	NonceStore
+	Lock(ctx context.Context) (unlock func(), err error)
+}
+
+// NonceManager 按 (链, 地址) 分配 nonce
+//
+// 同一热钱包的并发提现各自拿到不同的 nonce；广播被节点明确拒绝时 Release 归还，
+// 下次优先复用，避免留下空洞。定期与链上 pending nonce 对比：
+// 链上更大说明有外部发送，直接跟上；链上更小且不在分配中，说明该 nonce
+// 可能没有进入交易池（进程崩溃、广播丢失），连续 nonceGapSyncs 次同步都是
+// 同一个空洞时才重新分配出去填补，避免因为某个节点交易池不完整重复使用 nonce。
+type NonceManager struct {
+	client  NonceClient
+	chainID uint64
+	store   NonceStore
+
+	mu       sync.Mutex
+	accounts map[common.Address]*nonceAccount
+}
+
+// nonceAccount 单个地址的 nonce 状态
+type nonceAccount struct {
+	mu       sync.Mutex
+	loaded   bool
+	next     uint64          // 下一个新分配的 nonce
+	inflight map[uint64]bool // 已分配、尚未确认广播结果
+	released []uint64        // 归还或检测到的空洞，优先分配（升序）
+	syncedAt time.Time
+	gap      uint64 // 疑似空洞的 nonce
+	gapSeen  int    // 连续同步看到该空洞的次数
+}
+
+// NewNonceManager 创建 nonce 管理器，store 为 nil 时只保存在内存
+func NewNonceManager(client NonceClient, chainID uint64, store NonceStore) *NonceManager {
+	if store == nil {
+		store = NewMemoryNonceStore()
+	}
+	return &NonceManager{
+		client:   client,
+		chainID:  chainID,
+		store:    store,
+		accounts: make(map[common.Address]*nonceAccount),
+	}
+}
+
+func (m *NonceManager) account(addr common.Address) *nonceAccount {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	acc, ok := m.accounts[addr]
+	if !ok {
+		acc = &nonceAccount{inflight: make(map[uint64]bool)}
+		m.accounts[addr] = acc
+	}
+	return acc
+}
+
+// lockStore 存储支持跨进程锁时加锁，否则返回空操作
+func (m *NonceManager) lockStore(ctx context.Context) (func(), error) {
+	store, ok := m.store.(LockingNonceStore)
+	if !ok {
+		return func() {}, nil
+	}
+	unlock, err := store.Lock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("锁定 nonce 存储失败: %w", err)
+	}
+	return unlock, nil
+}
+
+// Acquire 分配一个 nonce，调用方广播后必须调用 Commit 或 Release
+func (m *NonceManager) Acquire(ctx context.Context, addr common.Address) (uint64, error) {
+	acc := m.account(addr)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	unlock, err := m.lockStore(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if !acc.loaded || time.Since(acc.syncedAt) > nonceSyncInterval {
+		if err := m.sync(ctx, addr, acc); err != nil {
+			return 0, err
+		}
+	}
+
+	// 其他进程可能已经分配了更大的 nonce（锁内读取的才是最新值）
+	saved, ok, err := m.store.Load(ctx, m.chainID, addr)
+	if err != nil {
+		return 0, fmt.Errorf("读取 nonce 失败: %w", err)
+	}
+	if ok && saved > acc.next {
+		acc.next = saved
+	}
+
+	if len(acc.released) > 0 {
+		nonce := acc.released[0]
+		acc.released = acc.released[1:]
+		acc.inflight[nonce] = true
+		return nonce, nil
+	}
+
+	nonce := acc.next
+	if err := m.store.Save(ctx, m.chainID, addr, nonce+1); err != nil {
+		return 0, fmt.Errorf("保存 nonce 失败: %w", err)
+	}
+	acc.next = nonce + 1
+	acc.inflight[nonce] = true
+	return nonce, nil
+}
+
+// Commit nonce 已被使用（交易进入交易池，或广播结果不确定）
+func (m *NonceManager) Commit(addr common.Address, nonce uint64) {
+	acc := m.account(addr)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	delete(acc.inflight, nonce)
+}
+
+// Release 交易被节点明确拒绝（没有进入交易池），归还 nonce 供下次使用
+// 网络超时等结果不确定的情况不要归还，由下次同步判断是否形成空洞
+func (m *NonceManager) Release(addr common.Address, nonce uint64) {
+	acc := m.account(addr)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	delete(acc.inflight, nonce)
+	acc.addReleased(nonce)
+}
+
+// Resync 与链上重新同步（例如广播返回 nonce too low 之后）
+func (m *NonceManager) Resync(ctx context.Context, addr common.Address) error {
+	acc := m.account(addr)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	unlock, err := m.lockStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return m.sync(ctx, addr, acc)
+}
+
+// sync 对比链上 pending nonce 修正本地状态（调用方持有 acc.mu）
+func (m *NonceManager) sync(ctx context.Context, addr common.Address, acc *nonceAccount) error {
+	pending, err := m.client.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return fmt.Errorf("获取 pending nonce 失败: %w", err)
+	}
+
+	if !acc.loaded {
+		saved, ok, err := m.store.Load(ctx, m.chainID, addr)
+		if err != nil {
+			return fmt.Errorf("读取 nonce 失败: %w", err)
+		}
+		acc.next = pending
+		if ok && saved > pending {
+			acc.next = saved
+		}
+		acc.loaded = true
+	}
+
+	// 链上已使用的 nonce 不能再分配
+	kept := acc.released[:0]
+	for _, nonce := range acc.released {
+		if nonce >= pending {
+			kept = append(kept, nonce)
+		}
+	}
+	acc.released = kept
+
+	gap := pending < acc.next && !acc.inflight[pending]
+	if !gap || acc.gap != pending {
+		acc.gap, acc.gapSeen = pending, 0
+	}
+
+	switch {
+	case pending > acc.next:
+		// 有其他程序用这个地址发过交易
+		log.Printf("nonce 落后于链上，跟进: chain=%d, addr=%s, %d -> %d", m.chainID, addr.Hex(), acc.next, pending)
+		acc.next = pending
+		if err := m.store.Save(ctx, m.chainID, addr, pending); err != nil {
+			return fmt.Errorf("保存 nonce 失败: %w", err)
+		}
+
+	case gap:
+		// pending 这个 nonce 已经分配过却不在交易池里，后面的交易都会卡住
+		// 更后面的 nonce 可能在排队，只填补第一个空洞，填上后下次同步再看
+		acc.gapSeen++
+		if acc.gapSeen < nonceGapSyncs {
+			log.Printf("nonce 可能存在空洞，继续观察: chain=%d, addr=%s, nonce=%d (%d/%d)",
+				m.chainID, addr.Hex(), pending, acc.gapSeen, nonceGapSyncs)
+			break
+		}
+		if acc.addReleased(pending) {
+			log.Printf("⚠️ 检测到 nonce 空洞: chain=%d, addr=%s, nonce=%d (已分配到 %d)", m.chainID, addr.Hex(), pending, acc.next-1)
+		}
+	}
+
+	acc.syncedAt = time.Now()
+	return nil
+}
+
+// addReleased 加入待复用列表（去重、保持升序）
+func (acc *nonceAccount) addReleased(nonce uint64) bool {
+	for _, n := range acc.released {
+		if n == nonce {
+			return false
+		}
+	}
+	acc.released = append(acc.released, nonce)
+	sort.Slice(acc.released, func(i, j int) bool { return acc.released[i] < acc.released[j] })
+	return true
+}
+
+// isNonceTooLow 广播失败是因为 nonce 已被链上交易使用
+func isNonceTooLow(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too low") ||
+		strings.Contains(msg, "already been used")
+}
+
+// isReplacementUnderpriced 交易池中已有同 nonce 的另一笔交易，且新交易的费用不足以替换它
+func isReplacementUnderpriced(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "replacement transaction underpriced") ||
+		strings.Contains(msg, "replacement fee too low")
+}
+
+// isAlreadyKnown 同一笔交易已经在交易池中（重复广播）
+func isAlreadyKnown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
+
+// nonceKey 存储键
+func nonceKey(chainID uint64, addr common.Address) string {
+	return fmt.Sprintf("%d:%s", chainID, addr.Hex())
+}
+
+// MemoryNonceStore 内存 nonce 存储（重启后以链上 pending nonce 为准）
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]uint64
+}
+
+// NewMemoryNonceStore 创建内存 nonce 存储
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]uint64)}
+}
+
+// Load 读取下一个 nonce
+func (s *MemoryNonceStore) Load(ctx context.Context, chainID uint64, addr common.Address) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next, ok := s.nonces[nonceKey(chainID, addr)]
+	return next, ok, nil
+}
+
+// Save 保存下一个 nonce
+func (s *MemoryNonceStore) Save(ctx context.Context, chainID uint64, addr common.Address, next uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nonces[nonceKey(chainID, addr)] = next
+	return nil
+}
+
+// FileNonceStore 文件 nonce 存储（JSON，先写临时文件再 rename）
+//
+// 同一文件可以由多个进程共用：Load 和 Save 每次重新读取文件，
+// NonceManager 分配期间持有 Lock 的跨进程文件锁。
+type FileNonceStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNonceStore 打开（或创建）nonce 文件
+func NewFileNonceStore(path string) (*FileNonceStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建 nonce 目录失败: %w", err)
+	}
+	s := &FileNonceStore{path: path}
+	if _, err := s.read(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lock 获取跨进程文件锁
+func (s *FileNonceStore) Lock(ctx context.Context) (func(), error) {
+	return lockFile(s.path + ".lock")
+}
+
+// Load 读取下一个 nonce
+func (s *FileNonceStore) Load(ctx context.Context, chainID uint64, addr common.Address) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nonces, err := s.read()
+	if err != nil {
+		return 0, false, err
+	}
+	next, ok := nonces[nonceKey(chainID, addr)]
+	return next, ok, nil
+}
+
+// Save 保存下一个 nonce 并落盘（重新读取文件，不覆盖其他进程保存的地址）
+func (s *FileNonceStore) Save(ctx context.Context, chainID uint64, addr common.Address, next uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nonces, err := s.read()
+	if err != nil {
+		return err
+	}
+	nonces[nonceKey(chainID, addr)] = next
+	data, err := json.MarshalIndent(nonces, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码 nonce 失败: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入 nonce 文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("替换 nonce 文件失败: %w", err)
+	}
+	return nil
+}
+
+// read 读取整个文件，不存在时为空
+func (s *FileNonceStore) read() (map[string]uint64, error) {
+	nonces := make(map[string]uint64)
+	data, err := os.ReadFile(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("读取 nonce 文件失败: %w", err)
+	default:
+		if err := json.Unmarshal(data, &nonces); err != nil {
+			return nil, fmt.Errorf("解析 nonce 文件失败: %w", err)
+		}
+	}
+	return nonces, nil
+}
@@ -0,0 +1,238 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fakeNonceClient 可调整的链上 pending nonce
+type fakeNonceClient struct {
+	mu      sync.Mutex
+	pending uint64
+}
+
+func (c *fakeNonceClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending, nil
+}
+
+func (c *fakeNonceClient) set(n uint64) {
+	c.mu.Lock()
+	c.pending = n
+	c.mu.Unlock()
+}
+
+var nonceAddr = common.HexToAddress("0x1f9840a85d5af5bf1d1762f925bdaddc4201f984")
+
+// acquire 分配 nonce，出错时终止测试
+func acquire(t *testing.T, m *NonceManager) uint64 {
+	t.Helper()
+	nonce, err := m.Acquire(context.Background(), nonceAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nonce
+}
+
+func TestNonceConcurrentAcquire(t *testing.T) {
+	m := NewNonceManager(&fakeNonceClient{pending: 5}, 1, nil)
+
+	const n = 50
+	nonces := make(chan uint64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := m.Acquire(context.Background(), nonceAddr)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			m.Commit(nonceAddr, nonce)
+			nonces <- nonce
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+
+	seen := make(map[uint64]bool)
+	for nonce := range nonces {
+		if seen[nonce] {
+			t.Fatalf("nonce %d allocated twice", nonce)
+		}
+		seen[nonce] = true
+	}
+	for nonce := uint64(5); nonce < 5+n; nonce++ {
+		if !seen[nonce] {
+			t.Errorf("nonce %d skipped", nonce)
+		}
+	}
+}
+
+func TestNonceReleaseReuse(t *testing.T) {
+	m := NewNonceManager(&fakeNonceClient{}, 1, nil)
+	for want := uint64(0); want < 3; want++ {
+		if got := acquire(t, m); got != want {
+			t.Fatalf("Acquire = %d, want %d", got, want)
+		}
+	}
+
+	// 被拒绝的 nonce 优先复用，按从小到大
+	m.Release(nonceAddr, 2)
+	m.Release(nonceAddr, 1)
+	for _, want := range []uint64{1, 2, 3} {
+		if got := acquire(t, m); got != want {
+			t.Errorf("Acquire = %d, want %d", got, want)
+		}
+	}
+}
+
+func TestNonceResync(t *testing.T) {
+	client := &fakeNonceClient{}
+	m := NewNonceManager(client, 1, nil)
+	m.Commit(nonceAddr, acquire(t, m))
+
+	// 外部程序用同一地址发了交易，广播返回 nonce too low 后重新同步
+	client.set(10)
+	m.Release(nonceAddr, acquire(t, m)) // 1 被拒绝后归还
+	if err := m.Resync(context.Background(), nonceAddr); err != nil {
+		t.Fatal(err)
+	}
+	if got := acquire(t, m); got != 10 {
+		t.Errorf("Acquire after resync = %d, want 10", got)
+	}
+}
+
+func TestNonceGapDetection(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeNonceClient{}
+	m := NewNonceManager(client, 1, nil)
+	for i := 0; i < 3; i++ {
+		m.Commit(nonceAddr, acquire(t, m))
+	}
+
+	// nonce 1 没有进入交易池：只看到一次不能复用
+	client.set(1)
+	for i := 1; i < nonceGapSyncs; i++ {
+		if err := m.Resync(ctx, nonceAddr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := acquire(t, m); got != 3 {
+		t.Fatalf("Acquire = %d, want 3 (gap not confirmed yet)", got)
+	}
+	m.Commit(nonceAddr, 3)
+
+	// 中途交易池恢复正常，计数清零
+	client.set(4)
+	m.Resync(ctx, nonceAddr)
+	client.set(1)
+	for i := 1; i < nonceGapSyncs; i++ {
+		m.Resync(ctx, nonceAddr)
+	}
+	if got := acquire(t, m); got != 4 {
+		t.Fatalf("Acquire = %d, want 4 (gap counter should reset)", got)
+	}
+	m.Commit(nonceAddr, 4)
+
+	// 连续多次同步都是同一个空洞，重新分配
+	m.Resync(ctx, nonceAddr)
+	if got := acquire(t, m); got != 1 {
+		t.Errorf("Acquire = %d, want 1 (gap filled)", got)
+	}
+}
+
+func TestFileNonceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.json")
+	store, err := NewFileNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewNonceManager(&fakeNonceClient{pending: 7}, 1, store)
+	acquire(t, m)
+	acquire(t, m)
+
+	// 重启后链上还没看到这两笔交易，也不能重复分配
+	store, err = NewFileNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, ok, _ := store.Load(context.Background(), 1, nonceAddr); !ok || next != 9 {
+		t.Fatalf("Load = %d, %v, want 9", next, ok)
+	}
+	m = NewNonceManager(&fakeNonceClient{pending: 7}, 1, store)
+	if got := acquire(t, m); got != 9 {
+		t.Errorf("Acquire after restart = %d, want 9", got)
+	}
+	if _, ok, _ := store.Load(context.Background(), 2, nonceAddr); ok {
+		t.Error("other chain should have no nonce")
+	}
+}
+
+// TestFileNonceStoreShared 两个进程（各自打开同一文件）同时出账，不会分配到同一个 nonce
+func TestFileNonceStoreShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.json")
+	client := &fakeNonceClient{pending: 7}
+	var managers []*NonceManager
+	for i := 0; i < 2; i++ {
+		store, err := NewFileNonceStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 两个进程都已按链上 pending 加载过，之后只能从文件得知对方的分配
+		m := NewNonceManager(client, 1, store)
+		acquire(t, m)
+		managers = append(managers, m)
+	}
+
+	const n = 20
+	var (
+		mu   sync.Mutex
+		seen = make(map[uint64]bool)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(m *NonceManager) {
+			defer wg.Done()
+			nonce, err := m.Acquire(context.Background(), nonceAddr)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[nonce] {
+				t.Errorf("nonce %d allocated twice", nonce)
+			}
+			seen[nonce] = true
+		}(managers[i%2])
+	}
+	wg.Wait()
+}
+
+func TestNonceErrors(t *testing.T) {
+	tests := []struct {
+		msg         string
+		tooLow      bool
+		underpriced bool
+	}{
+		{"nonce too low: next nonce 5, tx nonce 3", true, false},
+		{"Nonce has already been used", true, false},
+		{"replacement transaction underpriced", false, true},
+		{"already known", false, false},
+	}
+	for _, tt := range tests {
+		err := errors.New(tt.msg)
+		if isNonceTooLow(err) != tt.tooLow || isReplacementUnderpriced(err) != tt.underpriced {
+			t.Errorf("%q: tooLow=%v underpriced=%v", tt.msg, isNonceTooLow(err), isReplacementUnderpriced(err))
+		}
+	}
+}
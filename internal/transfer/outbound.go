@@ -0,0 +1,343 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxStatus 出账交易状态
+//
+//	created → signed → broadcast → mined → confirmed / failed
+//	                       ↘ replaced（同一 nonce 的其他交易上链）
+type TxStatus string
+
+const (
+	TxCreated   TxStatus = "created"   // 已记录，尚未签名
+	TxSigned    TxStatus = "signed"    // 已签名，广播前落盘
+	TxBroadcast TxStatus = "broadcast" // 已广播，等待上链
+	TxMined     TxStatus = "mined"     // 已上链，等待确认数
+	TxConfirmed TxStatus = "confirmed" // 达到确认数且执行成功
+	TxFailed    TxStatus = "failed"    // 执行失败或无法发送
+	TxReplaced  TxStatus = "replaced"  // nonce 被其他交易使用
+)
+
+// txTransitions 允许的状态变化
+// mined → broadcast 用于所在区块被重组；broadcast → broadcast 用于加速后更新哈希
+var txTransitions = map[TxStatus][]TxStatus{
+	TxCreated:   {TxSigned, TxFailed},
+	TxSigned:    {TxBroadcast, TxFailed, TxReplaced},
+	TxBroadcast: {TxBroadcast, TxMined, TxReplaced},
+	TxMined:     {TxConfirmed, TxFailed, TxBroadcast},
+}
+
+// errTxCancelled 上链的是 Cancel 发出的取消交易，转账没有执行
+const errTxCancelled = "交易已取消"
+
+// ErrTxRecordChanged 记录在读取之后已被其他写入方（出账进程、另一个跟踪器）修改
+var ErrTxRecordChanged = errors.New("出账记录已被修改")
+
+// Final 是否为终态
+func (s TxStatus) Final() bool {
+	return s == TxConfirmed || s == TxFailed || s == TxReplaced
+}
+
+// TxRecord 出账交易记录
+// RawTxs 保存每个版本（加速后追加）的已签名原始交易，重启后可以直接重新广播
+type TxRecord struct {
+	ID          string          `json:"id"` // 业务单号（提现单号）
+	ChainID     uint64          `json:"chain_id"`
+	From        common.Address  `json:"from"`
+	To          common.Address  `json:"to"`              // 收款地址（代币转账时不是交易的 to）
+	Token       *common.Address `json:"token,omitempty"` // 代币合约，为空表示原生币
+	Value       *big.Int        `json:"value"`           // 转账金额（最小单位）
+	Data        hexutil.Bytes   `json:"data,omitempty"`
+	Nonce       uint64          `json:"nonce"`
+	Status      TxStatus        `json:"status"`
+	TxHash      common.Hash     `json:"tx_hash"` // 最新版本；上链后为实际上链的版本
+	RawTxs      []hexutil.Bytes `json:"raw_txs,omitempty"`
+	BlockNumber uint64          `json:"block_number,omitempty"`
+	BlockHash   common.Hash     `json:"block_hash,omitempty"`
+	GasUsed     uint64          `json:"gas_used,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Version     uint64          `json:"version"` // 存储每次保存加一，用于发现并发修改
+}
+
+// transition 变更状态，不允许的变化返回错误
+func (r *TxRecord) transition(to TxStatus) error {
+	for _, next := range txTransitions[r.Status] {
+		if next == to {
+			r.Status = to
+			r.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return fmt.Errorf("交易 %s 状态不能从 %s 变为 %s", r.ID, r.Status, to)
+}
+
+// addSigned 记录新签名的版本
+func (r *TxRecord) addSigned(tx *types.Transaction) error {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("编码交易失败: %w", err)
+	}
+	r.RawTxs = append(r.RawTxs, raw)
+	r.TxHash = tx.Hash()
+	r.Nonce = tx.Nonce()
+	return nil
+}
+
+// Transactions 解码所有已签名版本
+func (r *TxRecord) Transactions() ([]*types.Transaction, error) {
+	txs := make([]*types.Transaction, 0, len(r.RawTxs))
+	for _, raw := range r.RawTxs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, fmt.Errorf("解码交易失败: %w", err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// mergeVersions 补上本记录没有的版本（SpeedUp / Cancel 另外加入的）
+// 已上链的记录 TxHash 保持为上链的版本
+func (r *TxRecord) mergeVersions(raws []hexutil.Bytes) error {
+	for _, raw := range raws {
+		if r.hasRaw(raw) {
+			continue
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return fmt.Errorf("解码交易失败: %w", err)
+		}
+		r.RawTxs = append(r.RawTxs, raw)
+		if r.BlockNumber == 0 {
+			r.TxHash = tx.Hash()
+		}
+	}
+	return nil
+}
+
+func (r *TxRecord) hasRaw(raw hexutil.Bytes) bool {
+	for _, known := range r.RawTxs {
+		if bytes.Equal(known, raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelled 上链的版本是否为 Cancel 发出的取消交易（转给自己的 0 金额交易）
+func (r *TxRecord) cancelled(hash common.Hash) bool {
+	txs, err := r.Transactions()
+	if err != nil {
+		return false
+	}
+	for _, tx := range txs {
+		if tx.Hash() == hash {
+			return tx.To() != nil && *tx.To() == r.From && tx.Value().Sign() == 0 && len(tx.Data()) == 0
+		}
+	}
+	return false
+}
+
+// setReceipt 记录上链信息
+func (r *TxRecord) setReceipt(receipt *types.Receipt) {
+	r.TxHash = receipt.TxHash
+	r.BlockNumber = receipt.BlockNumber.Uint64()
+	r.BlockHash = receipt.BlockHash
+	r.GasUsed = receipt.GasUsed
+}
+
+// newTxID 未指定业务单号时生成随机 ID
+func newTxID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// TxStore 出账交易存储
+type TxStore interface {
+	Save(ctx context.Context, record *TxRecord) error // 新增或覆盖
+	// SaveIfUnchanged 存储中的版本仍是 record.Version（读取时的版本）才保存，否则返回 ErrTxRecordChanged
+	SaveIfUnchanged(ctx context.Context, record *TxRecord) error
+	Get(ctx context.Context, id string) (*TxRecord, error)
+	ListPending(ctx context.Context, chainID uint64) ([]*TxRecord, error) // 未到终态的记录
+}
+
+// FileTxStore 文件出账交易存储（JSON 数组，每次变更整体重写）
+//
+// cli 出账进程写入、worker 的 Tracker 跟踪，两边共用同一个文件：
+// 每次读写都持有文件锁并重新读取文件，不缓存其他进程可能已修改的内容。
+type FileTxStore struct {
+	path string
+
+	mu      sync.Mutex
+	records map[string]*TxRecord // 最近一次读取的内容
+}
+
+// NewFileTxStore 打开（或创建）出账交易文件
+func NewFileTxStore(path string) (*FileTxStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建出账交易目录失败: %w", err)
+	}
+
+	s := &FileTxStore{path: path}
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s, nil
+}
+
+// Save 保存记录（存副本，调用方之后修改不影响存储）
+// 已到终态的记录不再被覆盖，避免另一个进程用过期的状态改回去
+func (s *FileTxStore) Save(ctx context.Context, record *TxRecord) error {
+	return s.save(record, false)
+}
+
+// SaveIfUnchanged 读取后没有其他写入时才保存
+// Tracker 根据读取时的版本推进状态，期间出账进程保存了加速版本的话，
+// 它的判断（例如 nonce 被“其他交易”占用）可能是错的，这一轮放弃，下一轮重新读取
+func (s *FileTxStore) SaveIfUnchanged(ctx context.Context, record *TxRecord) error {
+	return s.save(record, true)
+}
+
+func (s *FileTxStore) save(record *TxRecord, checkVersion bool) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var version uint64
+	if existing, ok := s.records[record.ID]; ok {
+		version = existing.Version
+		if existing.Status.Final() && existing.Status != record.Status {
+			return fmt.Errorf("出账记录 %s 已是终态 %s，不能改为 %s", record.ID, existing.Status, record.Status)
+		}
+	}
+	if checkVersion && version != record.Version {
+		return fmt.Errorf("%w: %s (版本 %d，读取时 %d)", ErrTxRecordChanged, record.ID, version, record.Version)
+	}
+	cp := *record
+	cp.RawTxs = append([]hexutil.Bytes(nil), record.RawTxs...)
+	cp.Version = version + 1
+	s.records[record.ID] = &cp
+	return s.flush()
+}
+
+// Get 按 ID 查询，不存在时返回 nil
+func (s *FileTxStore) Get(ctx context.Context, id string) (*TxRecord, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *record
+	return &cp, nil
+}
+
+// ListPending 指定链未到终态的记录，按创建时间排序
+func (s *FileTxStore) ListPending(ctx context.Context, chainID uint64) ([]*TxRecord, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var records []*TxRecord
+	for _, record := range s.records {
+		if record.ChainID == chainID && !record.Status.Final() {
+			cp := *record
+			records = append(records, &cp)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	return records, nil
+}
+
+// lock 加进程内和跨进程锁，并重新读取文件
+func (s *FileTxStore) lock() (func(), error) {
+	s.mu.Lock()
+	unlockFile, err := lockFile(s.path + ".lock")
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	unlock := func() {
+		unlockFile()
+		s.mu.Unlock()
+	}
+	if err := s.load(); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// load 读取文件内容，文件不存在时为空
+func (s *FileTxStore) load() error {
+	s.records = make(map[string]*TxRecord)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取出账交易失败: %w", err)
+	}
+
+	var records []*TxRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("解析出账交易失败: %w", err)
+	}
+	for _, record := range records {
+		s.records[record.ID] = record
+	}
+	return nil
+}
+
+// flush 先写临时文件再 rename
+func (s *FileTxStore) flush() error {
+	records := make([]*TxRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码出账交易失败: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入出账交易失败: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("替换出账交易文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTxTransitions(t *testing.T) {
+	cases := []struct {
+		from, to TxStatus
+		ok       bool
+	}{
+		{TxCreated, TxSigned, true},
+		{TxCreated, TxBroadcast, false},
+		{TxSigned, TxBroadcast, true},
+		{TxSigned, TxReplaced, true},
+		{TxBroadcast, TxBroadcast, true}, // 加速
+		{TxBroadcast, TxMined, true},
+		{TxBroadcast, TxConfirmed, false},
+		{TxMined, TxConfirmed, true},
+		{TxMined, TxBroadcast, true}, // 重组
+		{TxConfirmed, TxBroadcast, false},
+		{TxFailed, TxSigned, false},
+		{TxReplaced, TxMined, false},
+	}
+	for _, c := range cases {
+		r := &TxRecord{ID: "t", Status: c.from}
+		err := r.transition(c.to)
+		if (err == nil) != c.ok {
+			t.Errorf("%s → %s: err=%v, want ok=%v", c.from, c.to, err, c.ok)
+		}
+		if want := map[bool]TxStatus{true: c.to, false: c.from}[c.ok]; r.Status != want {
+			t.Errorf("%s → %s: status = %s", c.from, c.to, r.Status)
+		}
+	}
+}
+
+func TestFileTxStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbound.json")
+	s1, err := NewFileTxStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, from := signedTx(t, 7, 1)
+	now := time.Now()
+	a := &TxRecord{ID: "a", ChainID: 1, From: from, Status: TxSigned, CreatedAt: now}
+	if err := a.addSigned(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	a.Status = TxFailed // 保存的是副本，不影响存储
+
+	// 另一个进程打开同一个文件
+	s2, err := NewFileTxStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s2.Get(ctx, "a")
+	if err != nil || got == nil || got.Status != TxSigned {
+		t.Fatalf("Get = %v, %v", got, err)
+	}
+	txs, err := got.Transactions()
+	if err != nil || len(txs) != 1 || txs[0].Hash() != tx.Hash() || got.Nonce != 7 {
+		t.Fatalf("raw tx round trip: %v", err)
+	}
+	if missing, _ := s2.Get(ctx, "missing"); missing != nil {
+		t.Error("Get of unknown id should return nil")
+	}
+
+	// 之后写入的记录，先打开的实例也能读到
+	for _, r := range []*TxRecord{
+		{ID: "b", ChainID: 1, Status: TxBroadcast, CreatedAt: now.Add(-time.Minute)},
+		{ID: "c", ChainID: 1, Status: TxConfirmed, CreatedAt: now},
+		{ID: "d", ChainID: 2, Status: TxBroadcast, CreatedAt: now},
+	} {
+		if err := s2.Save(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending, err := s1.ListPending(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != "b" || pending[1].ID != "a" {
+		t.Fatalf("ListPending = %v", pending)
+	}
+
+	// 读取之后被另一个实例保存过，按旧版本保存会失败
+	stale, _ := s1.Get(ctx, "b")
+	fresh, _ := s2.Get(ctx, "b")
+	if err := s2.SaveIfUnchanged(ctx, fresh); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.SaveIfUnchanged(ctx, stale); !errors.Is(err, ErrTxRecordChanged) {
+		t.Errorf("stale save = %v, want ErrTxRecordChanged", err)
+	}
+
+	// 终态记录不能被过期的状态覆盖
+	if err := s1.Save(ctx, &TxRecord{ID: "c", ChainID: 1, Status: TxMined}); err == nil {
+		t.Error("overwriting a final record should fail")
+	}
+}
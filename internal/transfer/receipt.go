@@ -0,0 +1,188 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrTxDropped 交易不在交易池中也没有上链（被节点丢弃），nonce 仍未被使用
+	ErrTxDropped = errors.New("交易已被丢弃")
+	// ErrTxReplaced 同一 nonce 的另一笔交易已经上链
+	ErrTxReplaced = errors.New("交易已被替换")
+)
+
+const (
+	defaultPollInterval = 3 * time.Second
+	defaultDropTimeout  = 2 * time.Minute
+)
+
+// ReceiptClient 等待收据需要的链上查询
+type ReceiptClient interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// WaitConfig 等待确认配置
+type WaitConfig struct {
+	Confirmations uint64        // 需要的确认数（包含所在区块，0 视为 1）
+	PollInterval  time.Duration // 轮询间隔（默认 3s）
+	Timeout       time.Duration // 最长等待时间（0 表示只受 ctx 控制）
+	DropTimeout   time.Duration // 交易从交易池消失多久后判定为丢弃（默认 2m）
+}
+
+// Waiter 轮询等待交易上链并达到确认数
+//
+// 收据出现后继续等待确认数，期间所在区块被重组掉则回到等待上链；
+// 交易没有上链而同一 nonce 已被使用时返回 ErrTxReplaced，
+// 交易从交易池消失超过 DropTimeout 且 nonce 未被使用时返回 ErrTxDropped。
+// 加速（替换）过的交易把各个版本一起传入，任意一笔上链即可。
+type Waiter struct {
+	client ReceiptClient
+	cfg    WaitConfig
+}
+
+// NewWaiter 创建收据等待器
+func NewWaiter(client ReceiptClient, cfg WaitConfig) *Waiter {
+	if cfg.Confirmations == 0 {
+		cfg.Confirmations = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.DropTimeout <= 0 {
+		cfg.DropTimeout = defaultDropTimeout
+	}
+	return &Waiter{client: client, cfg: cfg}
+}
+
+// Wait 等待交易达到确认数，返回收据
+// txs 为同一 nonce 的各个版本（原交易及加速后的替换交易）
+func (w *Waiter) Wait(ctx context.Context, txs ...*types.Transaction) (*types.Receipt, error) {
+	if len(txs) == 0 {
+		return nil, errors.New("没有需要等待的交易")
+	}
+	tx := txs[len(txs)-1]
+
+	if w.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.cfg.Timeout)
+		defer cancel()
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, fmt.Errorf("解析发送地址失败: %w", err)
+	}
+
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var missingSince time.Time
+	for {
+		receipt, done, err := w.poll(ctx, txs, from, &missingSince)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待交易 %s 超时: %w", tx.Hash().Hex(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll 检查一次交易状态，达到确认数时 done 为 true
+// 查询失败只记录日志，下一轮重试；只有丢弃、替换返回错误
+func (w *Waiter) poll(ctx context.Context, txs []*types.Transaction, from common.Address, missingSince *time.Time) (*types.Receipt, bool, error) {
+	tx := txs[len(txs)-1]
+	receipt, err := w.receipt(ctx, txs)
+	switch {
+	case err == nil:
+		*missingSince = time.Time{}
+		return receipt, w.confirmed(ctx, receipt), nil
+	case !errors.Is(err, ethereum.NotFound):
+		log.Printf("查询收据失败: tx=%s: %v", tx.Hash().Hex(), err)
+		return nil, false, nil
+	}
+
+	// 还没有上链：nonce 被占用说明被替换
+	nonce, err := w.client.NonceAt(ctx, from, nil)
+	if err != nil {
+		log.Printf("查询 nonce 失败: %s: %v", from.Hex(), err)
+		return nil, false, nil
+	}
+	if nonce > tx.Nonce() {
+		// 两次查询之间刚好上链，再确认一次收据
+		if _, err := w.receipt(ctx, txs); err == nil {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("%w: tx=%s, nonce=%d", ErrTxReplaced, tx.Hash().Hex(), tx.Nonce())
+	}
+
+	// 不在交易池中：持续 DropTimeout 才判定丢弃（多节点之间交易池可能不同步）
+	// 只看最新的版本，旧版本被替换后本来就会从交易池消失
+	_, _, err = w.client.TransactionByHash(ctx, tx.Hash())
+	switch {
+	case err == nil:
+		*missingSince = time.Time{}
+	case errors.Is(err, ethereum.NotFound):
+		if missingSince.IsZero() {
+			*missingSince = time.Now()
+		} else if time.Since(*missingSince) > w.cfg.DropTimeout {
+			return nil, false, fmt.Errorf("%w: tx=%s, nonce=%d", ErrTxDropped, tx.Hash().Hex(), tx.Nonce())
+		}
+	default:
+		log.Printf("查询交易失败: tx=%s: %v", tx.Hash().Hex(), err)
+	}
+	return nil, false, nil
+}
+
+// receipt 查询各个版本的收据，都没有时返回 ethereum.NotFound
+func (w *Waiter) receipt(ctx context.Context, txs []*types.Transaction) (*types.Receipt, error) {
+	for i := len(txs) - 1; i >= 0; i-- {
+		receipt, err := w.client.TransactionReceipt(ctx, txs[i].Hash())
+		if err == nil || !errors.Is(err, ethereum.NotFound) {
+			return receipt, err
+		}
+	}
+	return nil, ethereum.NotFound
+}
+
+// confirmed 收据是否已达到确认数且所在区块仍在主链上
+func (w *Waiter) confirmed(ctx context.Context, receipt *types.Receipt) bool {
+	head, err := w.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		log.Printf("获取最新区块失败: %v", err)
+		return false
+	}
+	mined := receipt.BlockNumber.Uint64()
+	if head.Number.Uint64() < mined || head.Number.Uint64()-mined+1 < w.cfg.Confirmations {
+		return false
+	}
+
+	header, err := w.client.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		log.Printf("获取区块 %d 失败: %v", mined, err)
+		return false
+	}
+	if header.Hash() != receipt.BlockHash {
+		log.Printf("⚠️ 交易 %s 所在区块 %d 已被重组，继续等待", receipt.TxHash.Hex(), mined)
+		return false
+	}
+	return true
+}
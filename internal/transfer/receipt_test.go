@@ -0,0 +1,222 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeReceiptClient 可调整的链上状态：收据、交易池、nonce、链头
+type fakeReceiptClient struct {
+	mu       sync.Mutex
+	receipts map[common.Hash]*types.Receipt
+	pool     map[common.Hash]bool
+	nonce    uint64
+	head     uint64
+}
+
+func newFakeReceiptClient() *fakeReceiptClient {
+	return &fakeReceiptClient{
+		receipts: make(map[common.Hash]*types.Receipt),
+		pool:     make(map[common.Hash]bool),
+	}
+}
+
+func (c *fakeReceiptClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.receipts[txHash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (c *fakeReceiptClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pool[hash] {
+		return nil, true, nil
+	}
+	return nil, false, ethereum.NotFound
+}
+
+func (c *fakeReceiptClient) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nonce, nil
+}
+
+func (c *fakeReceiptClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number == nil {
+		return canonicalHeader(c.head), nil
+	}
+	if number.Uint64() > c.head {
+		return nil, ethereum.NotFound
+	}
+	return canonicalHeader(number.Uint64()), nil
+}
+
+// mine 交易在主链第 n 个区块上链
+func (c *fakeReceiptClient) mine(tx *types.Transaction, n uint64) *types.Receipt {
+	return c.mineAt(tx, n, canonicalHeader(n).Hash())
+}
+
+// mineAt 交易在指定哈希的区块上链（哈希与主链不同即为分叉块）
+func (c *fakeReceiptClient) mineAt(tx *types.Transaction, n uint64, blockHash common.Hash) *types.Receipt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := &types.Receipt{
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      tx.Hash(),
+		BlockNumber: new(big.Int).SetUint64(n),
+		BlockHash:   blockHash,
+	}
+	c.receipts[tx.Hash()] = r
+	delete(c.pool, tx.Hash())
+	if c.nonce <= tx.Nonce() {
+		c.nonce = tx.Nonce() + 1
+	}
+	return r
+}
+
+func (c *fakeReceiptClient) setHead(n uint64) {
+	c.mu.Lock()
+	c.head = n
+	c.mu.Unlock()
+}
+
+func (c *fakeReceiptClient) unmine(tx *types.Transaction) {
+	c.mu.Lock()
+	delete(c.receipts, tx.Hash())
+	c.nonce = tx.Nonce()
+	c.pool[tx.Hash()] = true
+	c.mu.Unlock()
+}
+
+// canonicalHeader 主链区块头，哈希只由高度决定
+func canonicalHeader(n uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(n), Difficulty: new(big.Int)}
+}
+
+// signedTx 签名一笔指定 nonce 和小费的转账
+func signedTx(t *testing.T, nonce uint64, tip int64) (*types.Transaction, common.Address) {
+	t.Helper()
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(tip),
+		GasFeeCap: big.NewInt(100 + tip),
+		Gas:       21000,
+		To:        &common.Address{1},
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func TestWaiterConfirmations(t *testing.T) {
+	client := newFakeReceiptClient()
+	tx, from := signedTx(t, 0, 1)
+	w := NewWaiter(client, WaitConfig{Confirmations: 3})
+
+	var missing time.Time
+	client.pool[tx.Hash()] = true
+	if _, done, err := w.poll(context.Background(), []*types.Transaction{tx}, from, &missing); done || err != nil {
+		t.Fatalf("pending tx: done=%v err=%v", done, err)
+	}
+
+	want := client.mine(tx, 10)
+	client.setHead(11)
+	if _, done, _ := w.poll(context.Background(), []*types.Transaction{tx}, from, &missing); done {
+		t.Fatal("2 confirmations should not be enough")
+	}
+
+	client.setHead(12)
+	receipt, done, err := w.poll(context.Background(), []*types.Transaction{tx}, from, &missing)
+	if !done || err != nil || receipt != want {
+		t.Fatalf("3 confirmations: done=%v err=%v", done, err)
+	}
+}
+
+func TestWaiterReorgedReceipt(t *testing.T) {
+	client := newFakeReceiptClient()
+	tx, from := signedTx(t, 0, 1)
+	w := NewWaiter(client, WaitConfig{Confirmations: 2})
+	txs := []*types.Transaction{tx}
+	var missing time.Time
+
+	// 收据所在区块已不在主链上，确认数再多也不能算完成
+	client.mineAt(tx, 10, common.HexToHash("0xdead"))
+	client.setHead(20)
+	if _, done, err := w.poll(context.Background(), txs, from, &missing); done || err != nil {
+		t.Fatalf("reorged receipt: done=%v err=%v", done, err)
+	}
+
+	// 重组后回到交易池，不是被替换
+	client.unmine(tx)
+	if _, done, err := w.poll(context.Background(), txs, from, &missing); done || err != nil {
+		t.Fatalf("back in pool: done=%v err=%v", done, err)
+	}
+
+	// 重新打包到主链
+	client.mine(tx, 21)
+	client.setHead(22)
+	receipt, done, err := w.poll(context.Background(), txs, from, &missing)
+	if !done || err != nil || receipt.BlockNumber.Uint64() != 21 {
+		t.Fatalf("re-mined: done=%v err=%v", done, err)
+	}
+}
+
+func TestWaiterReplaced(t *testing.T) {
+	client := newFakeReceiptClient()
+	tx, _ := signedTx(t, 3, 1)
+	other, _ := signedTx(t, 3, 5)
+	client.mine(other, 10)
+	client.setHead(10)
+
+	w := NewWaiter(client, WaitConfig{PollInterval: 10 * time.Millisecond, Timeout: time.Second})
+	if _, err := w.Wait(context.Background(), tx); !errors.Is(err, ErrTxReplaced) {
+		t.Fatalf("want ErrTxReplaced, got %v", err)
+	}
+
+	// 加速后的版本一起传入时，任意一个上链都算成功
+	receipt, err := w.Wait(context.Background(), tx, other)
+	if err != nil || receipt.TxHash != other.Hash() {
+		t.Fatalf("replacement mined: %v", err)
+	}
+}
+
+func TestWaiterDropped(t *testing.T) {
+	client := newFakeReceiptClient()
+	tx, from := signedTx(t, 0, 1)
+	w := NewWaiter(client, WaitConfig{PollInterval: 10 * time.Millisecond, DropTimeout: 100 * time.Millisecond, Timeout: time.Second})
+
+	// 第一次发现不在交易池只开始计时
+	var missing time.Time
+	if _, _, err := w.poll(context.Background(), []*types.Transaction{tx}, from, &missing); err != nil || missing.IsZero() {
+		t.Fatalf("first miss: err=%v", err)
+	}
+
+	start := time.Now()
+	_, err := w.Wait(context.Background(), tx)
+	if !errors.Is(err, ErrTxDropped) {
+		t.Fatalf("want ErrTxDropped, got %v", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatal("dropped before DropTimeout")
+	}
+}
@@ -0,0 +1,263 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"wallet/pkg/gas"
+	"wallet/pkg/signer"
+)
+
+// minBumpPercent 替换交易的最低加价比例（geth 交易池默认要求 tip 和 feeCap 都至少高 10%）
+const minBumpPercent = 10
+
+const defaultMaxBumps = 3
+
+// errFeeCapExceeded 加速后的费用超过 BumpPolicy.MaxFee
+var errFeeCapExceeded = errors.New("加速后费用超过上限")
+
+// BumpPolicy 自动加速策略
+// 交易发出后超过 After 仍未上链，就按当前费用和 +10% 中的较大者重新发送。
+// 原生币 SendMax 的交易没有余额支付更高的费用，不自动加速
+type BumpPolicy struct {
+	After    time.Duration // 多久未上链开始加速（0 表示不自动加速）
+	Speed    gas.Speed     // 加速时参考的费用档位
+	MaxBumps int           // 最多加速次数（默认 3）
+	MaxFee   *big.Int      // 费用上限（gasFeeCap 或 gasPrice），超过则停止加速；nil 不限制
+}
+
+// SetBumpPolicy 设置 Execute 等待确认期间的自动加速策略
+func (t *Transfer) SetBumpPolicy(policy BumpPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if policy.MaxBumps <= 0 {
+		policy.MaxBumps = defaultMaxBumps
+	}
+	t.bump = policy
+}
+
+// SpeedUp 用相同 nonce 重新发送交易，费用取 speed 档位当前费用和原交易 +10% 中的较大者
+// 设置了 TxStore 且交易属于某条出账记录时，新版本广播前加入该记录
+func (t *Transfer) SpeedUp(ctx context.Context, tx *types.Transaction, s signer.Signer, speed gas.Speed) (*types.Transaction, error) {
+	return t.replaceRecorded(ctx, tx, s, speed, false)
+}
+
+// Cancel 用相同 nonce 发送一笔 0 金额的转给自己的交易，使原交易失效
+// 取消交易同样需要比原交易高 10% 以上的费用，按 Fast 档位出价。
+// 交易属于某条出账记录时同样加入该记录，取消交易上链后记录为 failed
+func (t *Transfer) Cancel(ctx context.Context, tx *types.Transaction, s signer.Signer) (*types.Transaction, error) {
+	return t.replaceRecorded(ctx, tx, s, gas.Fast, true)
+}
+
+// replaceRecorded 手动替换：新版本不在出账记录里的话，Tracker 查不到它的收据，
+// 上链后会把记录当作 nonce 被其他交易占用（replaced）
+func (t *Transfer) replaceRecorded(ctx context.Context, old *types.Transaction, s signer.Signer, speed gas.Speed, cancel bool) (*types.Transaction, error) {
+	store := t.txStore()
+	if store == nil {
+		return t.replace(ctx, old, s, speed, cancel, nil, nil)
+	}
+	chainID, err := t.client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取链 ID 失败: %w", err)
+	}
+	record, err := findRecord(ctx, store, chainID.Uint64(), old.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return t.replace(ctx, old, s, speed, cancel, nil, nil)
+	}
+
+	// 读取后记录被修改（出账进程自动加速、Tracker 推进状态）时不广播，由调用方重试
+	return t.replace(ctx, old, s, speed, cancel, nil, func(tx *types.Transaction) error {
+		next := *record
+		next.RawTxs = append([]hexutil.Bytes(nil), record.RawTxs...)
+		if err := next.addSigned(tx); err != nil {
+			return err
+		}
+		next.UpdatedAt = time.Now()
+		if err := store.SaveIfUnchanged(ctx, &next); err != nil {
+			return fmt.Errorf("保存出账记录失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// findRecord 查找包含该交易版本的未完成出账记录，没有时返回 nil
+func findRecord(ctx context.Context, store TxStore, chainID uint64, hash common.Hash) (*TxRecord, error) {
+	records, err := store.ListPending(ctx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("查询出账记录失败: %w", err)
+	}
+	for _, record := range records {
+		txs, err := record.Transactions()
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			if tx.Hash() == hash {
+				return record, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// replace 构造并发送同 nonce 的替换交易，maxFee 不为 nil 时费用超过上限不发送
+// beforeSend 不为 nil 时在签名后、广播前调用（落盘），返回错误则不广播
+func (t *Transfer) replace(ctx context.Context, old *types.Transaction, s signer.Signer, speed gas.Speed, cancel bool, maxFee *big.Int, beforeSend func(*types.Transaction) error) (*types.Transaction, error) {
+	_, err := t.client.TransactionReceipt(ctx, old.Hash())
+	if err == nil {
+		return nil, fmt.Errorf("交易 %s 已上链，无法替换", old.Hash().Hex())
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return nil, fmt.Errorf("查询收据失败: %w", err)
+	}
+
+	chainID, err := t.client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取链 ID 失败: %w", err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), old)
+	if err != nil {
+		return nil, fmt.Errorf("解析发送地址失败: %w", err)
+	}
+	if from != s.Address() {
+		return nil, fmt.Errorf("签名器和原交易发送地址不匹配")
+	}
+
+	suggested, err := gas.SuggestFees(ctx, t.client, speed)
+	if err != nil {
+		return nil, fmt.Errorf("估算 gas 失败: %w", err)
+	}
+	fees := bumpFees(old, suggested)
+
+	var tx *types.Transaction
+	if cancel {
+		fees.GasLimit = params.TxGas
+		tx = gas.CreateTransaction(old.Nonce(), &from, big.NewInt(0), nil, fees, chainID)
+	} else {
+		fees.GasLimit = old.Gas()
+		tx = gas.CreateTransaction(old.Nonce(), old.To(), old.Value(), old.Data(), fees, chainID)
+	}
+
+	if maxFee != nil && feeOf(tx).Cmp(maxFee) > 0 {
+		return nil, fmt.Errorf("%w: %s > %s", errFeeCapExceeded, feeOf(tx), maxFee)
+	}
+
+	signedTx, err := s.SignTx(ctx, tx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("签名失败: %w", err)
+	}
+	if beforeSend != nil {
+		if err := beforeSend(signedTx); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.client.SendTransaction(ctx, signedTx); err != nil && !isAlreadyKnown(err) {
+		return nil, fmt.Errorf("发送替换交易失败: %w", err)
+	}
+
+	log.Printf("已替换交易: nonce=%d, %s -> %s, cancel=%v", old.Nonce(), old.Hash().Hex(), signedTx.Hash().Hex(), cancel)
+	return signedTx, nil
+}
+
+// bumpFees 替换交易的费用：当前建议费用与原交易 +10% 取较大者
+// 交易类型与原交易保持一致
+func bumpFees(old *types.Transaction, suggested *gas.GasParams) *gas.GasParams {
+	if old.Type() == types.LegacyTxType {
+		current := suggested.GasPrice
+		if !suggested.IsLegacy {
+			current = suggested.GasFeeCap
+		}
+		return &gas.GasParams{
+			GasPrice: maxBig(bumpPrice(old.GasPrice()), current),
+			IsLegacy: true,
+		}
+	}
+
+	tip, feeCap := suggested.GasTipCap, suggested.GasFeeCap
+	if suggested.IsLegacy {
+		tip, feeCap = suggested.GasPrice, suggested.GasPrice
+	}
+	tip = maxBig(bumpPrice(old.GasTipCap()), tip)
+	feeCap = maxBig(bumpPrice(old.GasFeeCap()), feeCap)
+	if feeCap.Cmp(tip) < 0 {
+		feeCap = new(big.Int).Set(tip)
+	}
+	return &gas.GasParams{GasTipCap: tip, GasFeeCap: feeCap}
+}
+
+// bumpPrice 加价 10%（向上取整再加 1 wei，避免取整后刚好不够）
+func bumpPrice(price *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(price, big.NewInt(100+minBumpPercent))
+	bumped.Add(bumped, big.NewInt(99))
+	bumped.Div(bumped, big.NewInt(100))
+	return bumped.Add(bumped, big.NewInt(1))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if b != nil && b.Cmp(a) > 0 {
+		return new(big.Int).Set(b)
+	}
+	return new(big.Int).Set(a)
+}
+
+// waitWithBump 等待确认，超过 BumpPolicy.After 未上链时自动加速
+// save 保存可能已发到网络上的所有版本：加速交易签名后、广播前先落盘，中断后 Tracker 能跟踪到；
+// 节点明确拒绝时再保存一次去掉该版本，避免 Tracker 反复广播一笔无效的交易
+func (t *Transfer) waitWithBump(ctx context.Context, waiter *Waiter, tx *types.Transaction, s signer.Signer, policy BumpPolicy, save func([]*types.Transaction) error) (*types.Receipt, error) {
+	txs := []*types.Transaction{tx}
+	for {
+		if policy.After <= 0 || len(txs) > policy.MaxBumps {
+			return waiter.Wait(ctx, txs...)
+		}
+
+		wctx, cancel := context.WithTimeout(ctx, policy.After)
+		receipt, err := waiter.Wait(wctx, txs...)
+		cancel()
+		if err == nil || ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+			return receipt, err
+		}
+
+		last := txs[len(txs)-1]
+		bumped, err := t.replace(ctx, last, s, policy.Speed, false, policy.MaxFee, func(bumped *types.Transaction) error {
+			return save(append(txs[:len(txs):len(txs)], bumped))
+		})
+		if errors.Is(err, errFeeCapExceeded) {
+			log.Printf("⚠️ 停止自动加速: tx=%s: %v", last.Hash().Hex(), err)
+			policy.After = 0
+			continue
+		}
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) {
+			if serr := save(txs); serr != nil {
+				log.Printf("恢复出账记录失败: %v", serr)
+			}
+		}
+		if err != nil {
+			// 可能刚好上链，继续等待
+			log.Printf("自动加速失败: tx=%s: %v", last.Hash().Hex(), err)
+			continue
+		}
+		txs = append(txs, bumped)
+	}
+}
+
+// feeOf 交易愿意支付的最高单价
+func feeOf(tx *types.Transaction) *big.Int {
+	if tx.Type() == types.LegacyTxType {
+		return tx.GasPrice()
+	}
+	return tx.GasFeeCap()
+}
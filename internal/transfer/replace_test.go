@@ -0,0 +1,318 @@
+package transfer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+	"wallet/pkg/gas"
+	"wallet/pkg/signer"
+)
+
+func TestBumpFees(t *testing.T) {
+	gwei := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9)) }
+
+	// 行情没涨：至少 +10%
+	old := types.NewTx(&types.DynamicFeeTx{GasTipCap: gwei(2), GasFeeCap: gwei(30)})
+	fees := bumpFees(old, &gas.GasParams{GasTipCap: gwei(1), GasFeeCap: gwei(20)})
+	minTip := new(big.Int).Div(new(big.Int).Mul(gwei(2), big.NewInt(110)), big.NewInt(100))
+	minFee := new(big.Int).Div(new(big.Int).Mul(gwei(30), big.NewInt(110)), big.NewInt(100))
+	if fees.GasTipCap.Cmp(minTip) <= 0 || fees.GasFeeCap.Cmp(minFee) <= 0 {
+		t.Errorf("bumped fees too low: tip=%s fee=%s", fees.GasTipCap, fees.GasFeeCap)
+	}
+	if fees.IsLegacy {
+		t.Error("dynamic fee tx should stay dynamic")
+	}
+
+	// 行情涨得更多：按当前建议
+	fees = bumpFees(old, &gas.GasParams{GasTipCap: gwei(5), GasFeeCap: gwei(80)})
+	if fees.GasTipCap.Cmp(gwei(5)) != 0 || fees.GasFeeCap.Cmp(gwei(80)) != 0 {
+		t.Errorf("expected suggested fees, got tip=%s fee=%s", fees.GasTipCap, fees.GasFeeCap)
+	}
+
+	// Legacy 交易只调整 gasPrice
+	legacy := types.NewTx(&types.LegacyTx{GasPrice: gwei(3)})
+	fees = bumpFees(legacy, &gas.GasParams{GasPrice: gwei(3), IsLegacy: true})
+	if !fees.IsLegacy || fees.GasPrice.Cmp(gwei(3)) <= 0 {
+		t.Errorf("legacy bump = %+v", fees)
+	}
+}
+
+var replaceTo = common.HexToAddress("0x0000000000000000000000000000000000002000")
+
+// newBackend 创建模拟链，返回有 100 ETH 的账户私钥；只在调用 Commit 时出块
+func newBackend(t *testing.T) (*simulated.Backend, *ecdsa.PrivateKey) {
+	key, _ := crypto.GenerateKey()
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))},
+	})
+	t.Cleanup(func() { backend.Close() })
+	// 创世块之后出一个块并等索引建完，否则查询收据会返回 indexing in progress 而不是 NotFound
+	backend.Commit()
+	indexedReceipt(backend, common.Hash{})
+	return backend, key
+}
+
+// sendPending 发送一笔 1 ETH 的转账，不出块
+func sendPending(t *testing.T, backend *simulated.Backend, key *ecdsa.PrivateKey) *types.Transaction {
+	t.Helper()
+	ctx := context.Background()
+	client := backend.Client()
+	chainID, _ := client.ChainID(ctx)
+	nonce, _ := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
+	head, _ := client.HeaderByNumber(ctx, nil)
+
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: new(big.Int).Add(head.BaseFee, big.NewInt(params.GWei)),
+		Gas:       params.TxGas,
+		To:        &replaceTo,
+		Value:     big.NewInt(params.Ether),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// minedReceipt 出块后查询收据
+func minedReceipt(t *testing.T, backend *simulated.Backend, tx *types.Transaction) (*types.Receipt, error) {
+	t.Helper()
+	backend.Commit()
+	return indexedReceipt(backend, tx.Hash())
+}
+
+// indexedReceipt 查询收据，等待模拟链建完交易索引
+// 模拟链出块后异步建交易索引，索引完成前查询会返回 indexing is in progress
+func indexedReceipt(backend *simulated.Backend, hash common.Hash) (*types.Receipt, error) {
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		receipt, err := backend.Client().TransactionReceipt(context.Background(), hash)
+		if err == nil || !strings.Contains(err.Error(), "indexing") || time.Now().After(deadline) {
+			return receipt, err
+		}
+	}
+}
+
+func TestSpeedUp(t *testing.T) {
+	backend, key := newBackend(t)
+	tr := NewWithClient(backend.Client())
+	old := sendPending(t, backend, key)
+
+	tx, err := tr.SpeedUp(context.Background(), old, signer.NewLocal(key), gas.Normal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Nonce() != old.Nonce() || *tx.To() != *old.To() || tx.Value().Cmp(old.Value()) != 0 {
+		t.Errorf("speed up changed the transfer: nonce=%d to=%s value=%s", tx.Nonce(), tx.To(), tx.Value())
+	}
+	if tx.GasTipCap().Cmp(old.GasTipCap()) <= 0 || tx.GasFeeCap().Cmp(old.GasFeeCap()) <= 0 {
+		t.Errorf("fees not bumped: tip=%s fee=%s", tx.GasTipCap(), tx.GasFeeCap())
+	}
+
+	if _, err := minedReceipt(t, backend, tx); err != nil {
+		t.Fatalf("replacement not mined: %v", err)
+	}
+	if _, err := backend.Client().TransactionReceipt(context.Background(), old.Hash()); !errors.Is(err, ethereum.NotFound) {
+		t.Errorf("original should not be mined, got %v", err)
+	}
+
+	// 已上链的交易不能再替换
+	if _, err := tr.SpeedUp(context.Background(), tx, signer.NewLocal(key), gas.Normal); err == nil {
+		t.Error("speeding up a mined tx should fail")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	backend, key := newBackend(t)
+	tr := NewWithClient(backend.Client())
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	old := sendPending(t, backend, key)
+
+	// 签名器必须是原交易的发送者
+	other, _ := crypto.GenerateKey()
+	if _, err := tr.Cancel(context.Background(), old, signer.NewLocal(other)); err == nil {
+		t.Error("cancel with another signer should fail")
+	}
+
+	tx, err := tr.Cancel(context.Background(), old, signer.NewLocal(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Nonce() != old.Nonce() || *tx.To() != from || tx.Value().Sign() != 0 || tx.Gas() != params.TxGas {
+		t.Errorf("cancel tx = nonce %d to %s value %s gas %d", tx.Nonce(), tx.To(), tx.Value(), tx.Gas())
+	}
+	if _, err := minedReceipt(t, backend, tx); err != nil {
+		t.Fatalf("cancel tx not mined: %v", err)
+	}
+	balance, _ := backend.Client().BalanceAt(context.Background(), replaceTo, nil)
+	if balance.Sign() != 0 {
+		t.Errorf("cancelled transfer still paid %s", balance)
+	}
+}
+
+// sendRecorder 记录经由 Transfer 广播的交易（即每次自动加速）
+type sendRecorder struct {
+	Client
+	sent chan *types.Transaction
+}
+
+func (r *sendRecorder) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	r.sent <- tx
+	return r.Client.SendTransaction(ctx, tx)
+}
+
+// waitBumps 后台执行 waitWithBump，返回结果通道和每次加速的交易
+func waitBumps(backend *simulated.Backend, waiter *Waiter, tx *types.Transaction, key *ecdsa.PrivateKey, policy BumpPolicy) (<-chan *types.Receipt, <-chan error, chan *types.Transaction) {
+	receipts, errs := make(chan *types.Receipt, 1), make(chan error, 1)
+	bumps := make(chan *types.Transaction, 10)
+	tr := NewWithClient(&sendRecorder{Client: backend.Client(), sent: bumps})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		receipt, err := tr.waitWithBump(ctx, waiter, tx, signer.NewLocal(key), policy, func([]*types.Transaction) error { return nil })
+		receipts <- receipt
+		errs <- err
+	}()
+	return receipts, errs, bumps
+}
+
+func TestWaitWithBumpMaxBumps(t *testing.T) {
+	backend, key := newBackend(t)
+	waiter := NewWaiter(backend.Client(), WaitConfig{PollInterval: 20 * time.Millisecond})
+	old := sendPending(t, backend, key)
+
+	receipts, errs, bumps := waitBumps(backend, waiter, old, key, BumpPolicy{After: 100 * time.Millisecond, Speed: gas.Normal, MaxBumps: 2})
+
+	var last *types.Transaction
+	for i := 0; i < 2; i++ {
+		select {
+		case last = <-bumps:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("bump %d not sent", i+1)
+		}
+	}
+	// 达到次数上限后只等待，不再加速
+	time.Sleep(300 * time.Millisecond)
+	if len(bumps) != 0 {
+		t.Fatalf("more than MaxBumps bumps")
+	}
+
+	backend.Commit()
+	receipt, err := <-receipts, <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.TxHash != last.Hash() {
+		t.Errorf("mined %s, want last bump %s", receipt.TxHash.Hex(), last.Hash().Hex())
+	}
+}
+
+func TestWaitWithBumpFeeCap(t *testing.T) {
+	backend, key := newBackend(t)
+	waiter := NewWaiter(backend.Client(), WaitConfig{PollInterval: 20 * time.Millisecond})
+	old := sendPending(t, backend, key)
+
+	// 上限等于原交易费用，任何加速都会超过
+	receipts, errs, bumps := waitBumps(backend, waiter, old, key, BumpPolicy{After: 50 * time.Millisecond, Speed: gas.Fast, MaxBumps: 3, MaxFee: old.GasFeeCap()})
+
+	time.Sleep(300 * time.Millisecond)
+	backend.Commit()
+	receipt, err := <-receipts, <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bumps) != 0 {
+		t.Errorf("bumped %d times above MaxFee", len(bumps))
+	}
+	if receipt.TxHash != old.Hash() {
+		t.Errorf("mined %s, want original", receipt.TxHash.Hex())
+	}
+}
+
+func TestWaitWithBumpOriginalMined(t *testing.T) {
+	backend, key := newBackend(t)
+	// 轮询间隔比加速等待长：原交易在两次轮询之间上链，加速时才发现
+	waiter := NewWaiter(backend.Client(), WaitConfig{PollInterval: 10 * time.Second})
+	old := sendPending(t, backend, key)
+
+	receipts, errs, bumps := waitBumps(backend, waiter, old, key, BumpPolicy{After: 300 * time.Millisecond, Speed: gas.Normal, MaxBumps: 3})
+
+	time.Sleep(100 * time.Millisecond)
+	backend.Commit()
+	receipt, err := <-receipts, <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bumps) != 0 {
+		t.Errorf("bumped a mined tx %d times", len(bumps))
+	}
+	if receipt.TxHash != old.Hash() {
+		t.Errorf("mined %s, want original", receipt.TxHash.Hex())
+	}
+}
+
+func TestReplaceRecorded(t *testing.T) {
+	for _, cancel := range []bool{false, true} {
+		backend, key := newBackend(t)
+		ctx := context.Background()
+		store, err := NewFileTxStore(filepath.Join(t.TempDir(), "outbound.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := NewWithClient(backend.Client())
+		tr.SetTxStore(store)
+
+		old := sendPending(t, backend, key)
+		record := &TxRecord{ID: "w-1", ChainID: 1337, From: crypto.PubkeyToAddress(key.PublicKey), To: replaceTo, Value: old.Value(), Status: TxBroadcast, CreatedAt: time.Now()}
+		if err := record.addSigned(old); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+
+		var tx *types.Transaction
+		if cancel {
+			tx, err = tr.Cancel(ctx, old, signer.NewLocal(key))
+		} else {
+			tx, err = tr.SpeedUp(ctx, old, signer.NewLocal(key), gas.Normal)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := minedReceipt(t, backend, tx); err != nil {
+			t.Fatal(err)
+		}
+		indexedReceipt(backend, old.Hash())
+
+		// 新版本已加入记录，Tracker 按它的收据确认，而不是当作被其他交易替换
+		tracker := NewTracker(backend.Client(), store, 1337, TrackerConfig{})
+		if err := tracker.CheckOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := store.Get(ctx, "w-1")
+		want := TxConfirmed
+		if cancel {
+			want = TxFailed
+		}
+		if got.Status != want || got.TxHash != tx.Hash() || len(got.RawTxs) != 2 {
+			t.Errorf("cancel=%v: record = %s %s with %d versions, want %s %s", cancel, got.Status, got.TxHash.Hex(), len(got.RawTxs), want, tx.Hash().Hex())
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RevertKind revert 数据的类型
+type RevertKind string
+
+const (
+	RevertUnknown RevertKind = "unknown" // 无法解码（或没有 revert 数据）
+	RevertError   RevertKind = "error"   // require / revert("...")，即 Error(string)
+	RevertPanic   RevertKind = "panic"   // assert、溢出、除零等，即 Panic(uint256)
+	RevertCustom  RevertKind = "custom"  // 合约自定义 error
+	RevertFalse   RevertKind = "false"   // 没有 revert，但代币 transfer 返回 false
+)
+
+var (
+	errorSelector = []byte{0x08, 0xc3, 0x79, 0xa0} // Error(string)
+	panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71} // Panic(uint256)
+)
+
+// panicReasons Solidity Panic 错误码
+var panicReasons = map[uint64]string{
+	0x00: "通用 panic",
+	0x01: "assert 失败",
+	0x11: "算术溢出",
+	0x12: "除以零",
+	0x21: "枚举值越界",
+	0x22: "存储字节数组编码错误",
+	0x31: "对空数组 pop",
+	0x32: "数组下标越界",
+	0x41: "内存分配过大",
+	0x51: "调用未初始化的函数指针",
+}
+
+// SimulationError 交易模拟执行会 revert
+// API 可以用 Kind / Reason 告诉用户提现无法执行的原因
+type SimulationError struct {
+	Kind   RevertKind
+	Reason string        // 解码后的原因：Error 的字符串、Panic 的说明或自定义 error 的签名和参数
+	Code   *big.Int      // Panic 错误码
+	Name   string        // 自定义 error 名称
+	Args   []interface{} // 自定义 error 参数
+	Data   hexutil.Bytes // 原始 revert 数据
+}
+
+func (e *SimulationError) Error() string {
+	if e.Reason == "" {
+		return "交易执行会失败（execution reverted）"
+	}
+	return fmt.Sprintf("交易执行会失败: %s", e.Reason)
+}
+
+// AddErrorABI 注册用于解码自定义 error 的合约 ABI
+func (t *Transfer) AddErrorABI(contractABI abi.ABI) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errorABIs = append(t.errorABIs, contractABI)
+}
+
+// Simulate 在 pending 状态上用 eth_call 模拟执行转账
+// 会 revert 时返回 *SimulationError，其他错误（节点故障等）原样返回
+func (t *Transfer) Simulate(ctx context.Context, req Request) error {
+	call, err := req.call()
+	if err != nil {
+		return err
+	}
+	return t.simulate(ctx, req.From, call)
+}
+
+// simulate 模拟执行交易内容
+func (t *Transfer) simulate(ctx context.Context, from common.Address, call *txCall) error {
+	ret, err := t.client.PendingCallContract(ctx, ethereum.CallMsg{
+		From:  from,
+		To:    &call.to,
+		Value: call.value,
+		Data:  call.data,
+	})
+	if err == nil {
+		return checkReturn(call, ret)
+	}
+
+	data, ok := revertData(err)
+	if !ok {
+		return err
+	}
+	t.mu.Lock()
+	abis := t.errorABIs
+	t.mu.Unlock()
+	return DecodeRevert(data, abis...)
+}
+
+// checkReturn 检查代币 transfer 的返回值
+// 没有返回值的代币（如 USDT）视为成功；有返回值时必须是 true，与 SafeERC20 的判断一致
+func checkReturn(call *txCall, ret []byte) error {
+	if !call.returnsBool || len(ret) == 0 {
+		return nil
+	}
+	if len(ret) >= 32 && new(big.Int).SetBytes(ret[:32]).Sign() != 0 {
+		return nil
+	}
+	return &SimulationError{Kind: RevertFalse, Reason: "代币 transfer 返回 false", Data: ret}
+}
+
+// revertData 从 RPC 错误中取出 revert 数据
+// 节点以 {"code": 3, "data": "0x..."} 返回；没有 data 但提示 execution reverted 的按空数据处理
+func revertData(err error) ([]byte, bool) {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if s, ok := dataErr.ErrorData().(string); ok {
+			if data, err := hexutil.Decode(s); err == nil {
+				return data, true
+			}
+		}
+	}
+	if strings.Contains(err.Error(), "execution reverted") {
+		return nil, true
+	}
+	return nil, false
+}
+
+// DecodeRevert 解码 revert 数据：Error(string)、Panic(uint256)，以及 abis 中定义的自定义 error
+func DecodeRevert(data []byte, abis ...abi.ABI) *SimulationError {
+	e := &SimulationError{Kind: RevertUnknown, Data: data}
+	if len(data) < 4 {
+		return e
+	}
+
+	selector := data[:4]
+	switch {
+	case bytes.Equal(selector, errorSelector):
+		if reason, err := abi.UnpackRevert(data); err == nil {
+			e.Kind, e.Reason = RevertError, reason
+		}
+		return e
+
+	case bytes.Equal(selector, panicSelector):
+		if len(data) != 4+32 {
+			return e
+		}
+		e.Kind, e.Code = RevertPanic, new(big.Int).SetBytes(data[4:])
+		reason, ok := panicReasons[e.Code.Uint64()]
+		if !ok || !e.Code.IsUint64() {
+			reason = "未知 panic"
+		}
+		e.Reason = fmt.Sprintf("%s (0x%x)", reason, e.Code)
+		return e
+	}
+
+	for _, contractABI := range abis {
+		for _, abiErr := range contractABI.Errors {
+			if !bytes.Equal(abiErr.ID[:4], selector) {
+				continue
+			}
+			args, err := abiErr.Inputs.Unpack(data[4:])
+			if err != nil {
+				continue
+			}
+			e.Kind, e.Name, e.Args = RevertCustom, abiErr.Name, args
+			e.Reason = fmt.Sprintf("%s%v", abiErr.Name, args)
+			return e
+		}
+	}
+	return e
+}
+
+// simulateBeforeSend Execute 的模拟步骤：revert 时中止，节点不支持等其他错误只记日志
+func (t *Transfer) simulateBeforeSend(ctx context.Context, from common.Address, call *txCall) error {
+	err := t.simulate(ctx, from, call)
+	var simErr *SimulationError
+	if errors.As(err, &simErr) {
+		return err
+	}
+	if err != nil {
+		log.Printf("模拟执行失败，跳过: %v", err)
+	}
+	return nil
+}
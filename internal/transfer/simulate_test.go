@@ -0,0 +1,87 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeRevert(t *testing.T) {
+	// require(false, "insufficient balance")
+	e := DecodeRevert(common.FromHex("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000014" +
+		"696e73756666696369656e742062616c616e6365000000000000000000000000"))
+	if e.Kind != RevertError || e.Reason != "insufficient balance" {
+		t.Errorf("Error(string) = %s %q", e.Kind, e.Reason)
+	}
+
+	// 算术溢出
+	e = DecodeRevert(common.FromHex("4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011"))
+	if e.Kind != RevertPanic || e.Code.Uint64() != 0x11 || !strings.Contains(e.Reason, "溢出") {
+		t.Errorf("Panic(uint256) = %s %q", e.Kind, e.Reason)
+	}
+
+	// error InsufficientBalance(uint256 available, uint256 required)
+	contractABI, err := abi.JSON(strings.NewReader(`[{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	abiErr := contractABI.Errors["InsufficientBalance"]
+	args, _ := abiErr.Inputs.Pack(big.NewInt(1), big.NewInt(2))
+	data := append(abiErr.ID[:4:4], args...)
+
+	e = DecodeRevert(data, contractABI)
+	if e.Kind != RevertCustom || e.Name != "InsufficientBalance" || len(e.Args) != 2 {
+		t.Errorf("custom error = %s %q", e.Kind, e.Reason)
+	}
+	if e = DecodeRevert(data); e.Kind != RevertUnknown {
+		t.Errorf("没有 ABI 时应无法解码, got %s", e.Kind)
+	}
+}
+
+func TestExecuteSimulationRevert(t *testing.T) {
+	tr, req := newBatch(t)
+	contract := deployDisperse(t, tr, req)
+
+	// 合约没有这个方法，会 revert
+	_, err := tr.Execute(context.Background(), Request{
+		ID:     "revert-1",
+		From:   req.From,
+		Signer: req.Signer,
+		To:     contract,
+		Amount: new(big.Int),
+		Data:   common.FromHex("deadbeef"),
+	})
+	var simErr *SimulationError
+	if !errors.As(err, &simErr) {
+		t.Fatalf("want SimulationError, got %v", err)
+	}
+}
+
+func TestCheckReturn(t *testing.T) {
+	token := &txCall{returnsBool: true}
+	cases := []struct {
+		call *txCall
+		ret  string
+		ok   bool
+	}{
+		{token, "", true}, // USDT 等没有返回值
+		{token, "0000000000000000000000000000000000000000000000000000000000000001", true},
+		{token, "0000000000000000000000000000000000000000000000000000000000000000", false},
+		{token, "01", false},
+		{&txCall{}, "0000000000000000000000000000000000000000000000000000000000000000", true}, // 不是代币转账
+	}
+	for _, c := range cases {
+		err := checkReturn(c.call, common.FromHex(c.ret))
+		var simErr *SimulationError
+		if c.ok != (err == nil) || (err != nil && (!errors.As(err, &simErr) || simErr.Kind != RevertFalse)) {
+			t.Errorf("checkReturn(%q) = %v, want ok=%v", c.ret, err, c.ok)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// erc20ABI 出账用到的 ERC-20 方法
+const erc20ABI = `[
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+var erc20 = mustParseABI(erc20ABI)
+
+// errNoTransferLog 收据成功但没有对应的 Transfer 事件
+// 部分代币失败时不 revert 而是返回 false，只看收据状态会误判为成功
+const errNoTransferLog = "收据中没有对应的 Transfer 事件，代币转账未生效"
+
+func mustParseABI(s string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// tokenTransferred 收据中是否有代币合约发出的 Transfer(from, to, amount) 事件
+func tokenTransferred(receipt *types.Receipt, token, from, to common.Address, amount *big.Int) bool {
+	event := erc20.Events["Transfer"].ID
+	for _, l := range receipt.Logs {
+		if l.Address != token || len(l.Topics) != 3 || l.Topics[0] != event {
+			continue
+		}
+		if common.BytesToAddress(l.Topics[1].Bytes()) != from || common.BytesToAddress(l.Topics[2].Bytes()) != to {
+			continue
+		}
+		if len(l.Data) == 32 && new(big.Int).SetBytes(l.Data).Cmp(amount) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeTokenTransfer 生成 ERC-20 transfer(to, amount) 调用数据
+func EncodeTokenTransfer(to common.Address, amount *big.Int) ([]byte, error) {
+	data, err := erc20.Pack("transfer", to, amount)
+	if err != nil {
+		return nil, fmt.Errorf("编码 transfer 调用失败: %w", err)
+	}
+	return data, nil
+}
+
+// TokenBalance 查询代币余额（最小单位）
+func (t *Transfer) TokenBalance(ctx context.Context, token, owner common.Address) (*big.Int, error) {
+	out, err := t.callToken(ctx, token, "balanceOf", owner)
+	if err != nil {
+		return nil, err
+	}
+	return abi.ConvertType(out[0], new(big.Int)).(*big.Int), nil
+}
+
+// TokenAllowance 查询 owner 授权给 spender 的代币额度
+func (t *Transfer) TokenAllowance(ctx context.Context, token, owner, spender common.Address) (*big.Int, error) {
+	out, err := t.callToken(ctx, token, "allowance", owner, spender)
+	if err != nil {
+		return nil, err
+	}
+	return abi.ConvertType(out[0], new(big.Int)).(*big.Int), nil
+}
+
+// TokenDecimals 查询代币精度（结果缓存）
+func (t *Transfer) TokenDecimals(ctx context.Context, token common.Address) (uint8, error) {
+	t.mu.Lock()
+	decimals, ok := t.decimals[token]
+	t.mu.Unlock()
+	if ok {
+		return decimals, nil
+	}
+
+	out, err := t.callToken(ctx, token, "decimals")
+	if err != nil {
+		return 0, err
+	}
+	decimals = out[0].(uint8)
+
+	t.mu.Lock()
+	if t.decimals == nil {
+		t.decimals = make(map[common.Address]uint8)
+	}
+	t.decimals[token] = decimals
+	t.mu.Unlock()
+	return decimals, nil
+}
+
+// callToken 调用代币合约的只读方法
+func (t *Transfer) callToken(ctx context.Context, token common.Address, method string, args ...interface{}) ([]interface{}, error) {
+	data, err := erc20.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("编码 %s 调用失败: %w", method, err)
+	}
+	result, err := t.client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("调用 %s.%s 失败: %w", token.Hex(), method, err)
+	}
+	out, err := erc20.Unpack(method, result)
+	if err != nil || len(out) == 0 {
+		return nil, fmt.Errorf("解析 %s.%s 返回值失败（不是 ERC-20 合约？）: %v", token.Hex(), method, err)
+	}
+	return out, nil
+}
+
+// ParseUnits 把十进制金额转换为最小单位，例如 ParseUnits("1.5", 6) = 1500000
+// 小数位超过 decimals 时返回错误，不做截断
+func ParseUnits(amount string, decimals uint8) (*big.Int, error) {
+	amount = strings.TrimSpace(amount)
+	whole, frac, _ := strings.Cut(amount, ".")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("金额格式错误: %q", amount)
+	}
+	if len(frac) > int(decimals) {
+		return nil, fmt.Errorf("金额 %s 超过精度（%d 位小数）", amount, decimals)
+	}
+
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok || value.Sign() < 0 || strings.ContainsAny(digits, "+-") {
+		return nil, fmt.Errorf("金额格式错误: %q", amount)
+	}
+	return value, nil
+}
+
+// FormatUnits 把最小单位转换为十进制金额（去掉末尾的 0）
+func FormatUnits(value *big.Int, decimals uint8) string {
+	s := new(big.Int).Abs(value).String()
+	if len(s) <= int(decimals) {
+		s = strings.Repeat("0", int(decimals)-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-int(decimals)], strings.TrimRight(s[len(s)-int(decimals):], "0")
+	if value.Sign() < 0 {
+		whole = "-" + whole
+	}
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
@@ -0,0 +1,92 @@
+package transfer
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestUnits(t *testing.T) {
+	cases := []struct {
+		in       string
+		decimals uint8
+		want     string
+	}{
+		{"1.5", 6, "1500000"},
+		{"0.000001", 6, "1"},
+		{"100", 18, "100000000000000000000"},
+		{".25", 2, "25"},
+	}
+	for _, c := range cases {
+		got, err := ParseUnits(c.in, c.decimals)
+		if err != nil {
+			t.Fatalf("ParseUnits(%q, %d): %v", c.in, c.decimals, err)
+		}
+		if got.String() != c.want {
+			t.Errorf("ParseUnits(%q, %d) = %s, want %s", c.in, c.decimals, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"", "1.0000001", "-1", "abc", "1.2.3"} {
+		if _, err := ParseUnits(bad, 6); err == nil {
+			t.Errorf("ParseUnits(%q) should fail", bad)
+		}
+	}
+
+	if got := FormatUnits(big.NewInt(1500000), 6); got != "1.5" {
+		t.Errorf("FormatUnits = %s", got)
+	}
+	if got := FormatUnits(big.NewInt(1), 6); got != "0.000001" {
+		t.Errorf("FormatUnits = %s", got)
+	}
+	if got := FormatUnits(big.NewInt(7), 0); got != "7" {
+		t.Errorf("FormatUnits = %s", got)
+	}
+}
+
+func TestEncodeTokenTransfer(t *testing.T) {
+	to := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb0")
+	data, err := EncodeTokenTransfer(to, big.NewInt(1e18))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := common.Hex2Bytes("a9059cbb000000000000000000000000742d35cc6634c0532925a3b844bc9e7595f0beb00000000000000000000000000000000000000000000000000de0b6b3a7640000")
+	if !bytes.Equal(data, want) {
+		t.Errorf("calldata = %x", data)
+	}
+}
+
+func TestTokenTransferred(t *testing.T) {
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	from := common.HexToAddress("0x1f9840a85d5af5bf1d1762f925bdaddc4201f984")
+	to := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb0")
+	amount := big.NewInt(1500000)
+
+	transfer := func(addr, from, to common.Address, value *big.Int) *types.Log {
+		return &types.Log{
+			Address: addr,
+			Topics:  []common.Hash{erc20.Events["Transfer"].ID, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:    common.LeftPadBytes(value.Bytes(), 32),
+		}
+	}
+	cases := []struct {
+		name string
+		logs []*types.Log
+		want bool
+	}{
+		{"matching log", []*types.Log{transfer(token, from, to, amount)}, true},
+		{"no logs (transfer returned false)", nil, false},
+		{"other token", []*types.Log{transfer(common.Address{1}, from, to, amount)}, false},
+		{"other recipient", []*types.Log{transfer(token, from, common.Address{2}, amount)}, false},
+		{"other amount", []*types.Log{transfer(token, from, to, big.NewInt(1))}, false},
+		{"among other logs", []*types.Log{transfer(token, to, from, amount), transfer(token, from, to, amount)}, true},
+	}
+	for _, c := range cases {
+		if got := tokenTransferred(&types.Receipt{Logs: c.logs}, token, from, to, amount); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	defaultTrackInterval = 15 * time.Second
+	// createdTimeout created 状态超过该时间说明签名前进程中断，交易从未发出
+	createdTimeout = 5 * time.Minute
+)
+
+// TrackerClient 跟踪出账交易需要的链上操作
+type TrackerClient interface {
+	ReceiptClient
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// TrackerConfig 出账交易跟踪配置
+type TrackerConfig struct {
+	Interval      time.Duration // 检查间隔（默认 15s）
+	Confirmations uint64        // 确认数（0 视为 1）
+	DropTimeout   time.Duration // 从交易池消失多久后重新广播（默认 2m）
+}
+
+// Tracker 后台跟踪未完成的出账交易
+//
+// 进程重启后从 TxStore 恢复：已签名未广播的直接广播，已广播但从交易池消失的
+// 用保存的原始交易重新广播，上链后等待确认数，区块被重组则回到 broadcast 继续等待。
+type Tracker struct {
+	client   TrackerClient
+	store    TxStore
+	chainID  uint64
+	waiter   *Waiter
+	interval time.Duration
+
+	mu      sync.Mutex
+	missing map[string]time.Time // 记录 ID → 首次发现不在交易池的时间
+}
+
+// NewTracker 创建出账交易跟踪器
+func NewTracker(client TrackerClient, store TxStore, chainID uint64, cfg TrackerConfig) *Tracker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultTrackInterval
+	}
+	return &Tracker{
+		client:   client,
+		store:    store,
+		chainID:  chainID,
+		waiter:   NewWaiter(client, WaitConfig{Confirmations: cfg.Confirmations, DropTimeout: cfg.DropTimeout}),
+		interval: cfg.Interval,
+		missing:  make(map[string]time.Time),
+	}
+}
+
+// Run 定期检查，直到 ctx 取消
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.CheckOnce(ctx); err != nil {
+			log.Printf("检查出账交易失败: chain=%d: %v", t.chainID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce 检查一遍所有未完成的出账交易
+func (t *Tracker) CheckOnce(ctx context.Context) error {
+	records, err := t.store.ListPending(ctx, t.chainID)
+	if err != nil {
+		return fmt.Errorf("查询出账记录失败: %w", err)
+	}
+
+	for _, record := range records {
+		before := *record
+		if err := t.check(ctx, record); err != nil {
+			log.Printf("出账交易 %s 检查失败: %v", record.ID, err)
+			continue
+		}
+		if record.Status != before.Status {
+			log.Printf("出账交易 %s: %s → %s, tx=%s", record.ID, before.Status, record.Status, record.TxHash.Hex())
+		}
+		if record.Status != TxBroadcast {
+			t.setMissing(record.ID, false)
+		}
+		if record.Status != before.Status || record.TxHash != before.TxHash || record.BlockHash != before.BlockHash {
+			// 检查期间出账进程可能保存了新的加速版本，按旧版本得出的结论不能覆盖它
+			err := t.store.SaveIfUnchanged(ctx, record)
+			switch {
+			case errors.Is(err, ErrTxRecordChanged):
+				log.Printf("出账记录 %s 检查期间已被更新，下一轮重新检查", record.ID)
+			case err != nil:
+				log.Printf("保存出账记录 %s 失败: %v", record.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// check 推进单条记录的状态（只修改 record，由调用方保存）
+func (t *Tracker) check(ctx context.Context, record *TxRecord) error {
+	switch record.Status {
+	case TxCreated:
+		if time.Since(record.UpdatedAt) > createdTimeout {
+			record.Error = "签名前中断，交易未发出"
+			return record.transition(TxFailed)
+		}
+		return nil
+
+	case TxSigned:
+		return t.broadcast(ctx, record)
+
+	case TxBroadcast:
+		return t.checkBroadcast(ctx, record)
+
+	case TxMined:
+		return t.checkMined(ctx, record)
+	}
+	return nil
+}
+
+// broadcast 广播最新版本（已签名未广播，或从交易池消失）
+func (t *Tracker) broadcast(ctx context.Context, record *TxRecord) error {
+	txs, err := record.Transactions()
+	if err != nil {
+		return err
+	}
+	if len(txs) == 0 {
+		return fmt.Errorf("没有已签名的交易")
+	}
+
+	err = t.client.SendTransaction(ctx, txs[len(txs)-1])
+	switch {
+	case err == nil, isAlreadyKnown(err):
+	case isNonceTooLow(err):
+		// nonce 已被使用：可能就是这笔交易已上链，交给 broadcast 状态判断
+	default:
+		return fmt.Errorf("广播失败: %w", err)
+	}
+
+	if record.Status == TxSigned {
+		return record.transition(TxBroadcast)
+	}
+	return nil
+}
+
+// checkBroadcast 已广播：上链则进入 mined，nonce 被占用则 replaced，
+// 不在交易池持续 DropTimeout 才重新广播（多节点之间交易池可能不同步，与 Waiter 一致）
+func (t *Tracker) checkBroadcast(ctx context.Context, record *TxRecord) error {
+	txs, err := record.Transactions()
+	if err != nil {
+		return err
+	}
+
+	receipt, err := t.waiter.receipt(ctx, txs)
+	if err == nil {
+		record.setReceipt(receipt)
+		if err := record.transition(TxMined); err != nil {
+			return err
+		}
+		return t.checkMined(ctx, record)
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return fmt.Errorf("查询收据失败: %w", err)
+	}
+
+	nonce, err := t.client.NonceAt(ctx, record.From, nil)
+	if err != nil {
+		return fmt.Errorf("查询 nonce 失败: %w", err)
+	}
+	if nonce > record.Nonce {
+		if _, err := t.waiter.receipt(ctx, txs); err == nil {
+			return nil // 刚好上链，下一轮处理
+		}
+		record.Error = ErrTxReplaced.Error()
+		return record.transition(TxReplaced)
+	}
+
+	_, _, err = t.client.TransactionByHash(ctx, record.TxHash)
+	switch {
+	case err == nil:
+		t.setMissing(record.ID, false)
+		return nil
+	case !errors.Is(err, ethereum.NotFound):
+		return err
+	case !t.setMissing(record.ID, true):
+		return nil
+	}
+	log.Printf("出账交易 %s 不在交易池中，重新广播: tx=%s", record.ID, record.TxHash.Hex())
+	t.setMissing(record.ID, false)
+	return t.broadcast(ctx, record)
+}
+
+// setMissing 更新记录不在交易池的状态，返回是否已持续超过 DropTimeout
+func (t *Tracker) setMissing(id string, missing bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !missing {
+		delete(t.missing, id)
+		return false
+	}
+	since, ok := t.missing[id]
+	if !ok {
+		t.missing[id] = time.Now()
+		return false
+	}
+	return time.Since(since) > t.waiter.cfg.DropTimeout
+}
+
+// checkMined 已上链：达到确认数后按执行结果进入 confirmed / failed
+func (t *Tracker) checkMined(ctx context.Context, record *TxRecord) error {
+	receipt, err := t.client.TransactionReceipt(ctx, record.TxHash)
+	if errors.Is(err, ethereum.NotFound) {
+		// 所在区块被重组掉，交易回到交易池（或需要重新广播）
+		log.Printf("⚠️ 出账交易 %s 所在区块 %d 已被重组", record.ID, record.BlockNumber)
+		record.BlockNumber, record.BlockHash = 0, common.Hash{}
+		return record.transition(TxBroadcast)
+	}
+	if err != nil {
+		return fmt.Errorf("查询收据失败: %w", err)
+	}
+	record.setReceipt(receipt)
+
+	if !t.waiter.confirmed(ctx, receipt) {
+		return nil
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		record.Error = "交易执行失败"
+		return record.transition(TxFailed)
+	}
+	if record.cancelled(receipt.TxHash) {
+		record.Error = errTxCancelled
+		return record.transition(TxFailed)
+	}
+	if record.Token != nil && !tokenTransferred(receipt, *record.Token, record.From, record.To, record.Value) {
+		record.Error = errNoTransferLog
+		return record.transition(TxFailed)
+	}
+	return record.transition(TxConfirmed)
+}
@@ -0,0 +1,243 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeTrackerClient 在 fakeReceiptClient 基础上记录广播的交易
+type fakeTrackerClient struct {
+	*fakeReceiptClient
+	sent    []*types.Transaction
+	sendErr error
+}
+
+func (c *fakeTrackerClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, tx)
+	if c.sendErr != nil {
+		return c.sendErr
+	}
+	c.pool[tx.Hash()] = true
+	return nil
+}
+
+// newTracked 创建指定状态的出账记录并保存，返回跟踪器
+func newTracked(t *testing.T, status TxStatus, cfg TrackerConfig) (*Tracker, *fakeTrackerClient, TxStore, *types.Transaction) {
+	t.Helper()
+	client := &fakeTrackerClient{fakeReceiptClient: newFakeReceiptClient()}
+	store, err := NewFileTxStore(filepath.Join(t.TempDir(), "outbound.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, from := signedTx(t, 4, 1)
+	client.nonce = tx.Nonce()
+	record := &TxRecord{ID: "w-1", ChainID: 1, From: from, Status: status, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := record.addSigned(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	return NewTracker(client, store, 1, cfg), client, store, tx
+}
+
+// trackedStatus 检查一轮后的记录状态
+func trackedStatus(t *testing.T, tracker *Tracker, store TxStore) *TxRecord {
+	t.Helper()
+	if err := tracker.CheckOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	record, err := store.Get(context.Background(), "w-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestTrackerCheck(t *testing.T) {
+	cases := []struct {
+		name   string
+		status TxStatus
+		setup  func(c *fakeTrackerClient, tx *types.Transaction)
+		want   TxStatus
+		sent   int
+	}{
+		{
+			name:   "signed → broadcast",
+			status: TxSigned,
+			want:   TxBroadcast,
+			sent:   1,
+		},
+		{
+			name:   "signed, nonce already used",
+			status: TxSigned,
+			setup:  func(c *fakeTrackerClient, tx *types.Transaction) { c.sendErr = errors.New("nonce too low") },
+			want:   TxBroadcast,
+			sent:   1,
+		},
+		{
+			name:   "broadcast, still in pool",
+			status: TxBroadcast,
+			setup:  func(c *fakeTrackerClient, tx *types.Transaction) { c.pool[tx.Hash()] = true },
+			want:   TxBroadcast,
+		},
+		{
+			name:   "broadcast, first miss waits for DropTimeout",
+			status: TxBroadcast,
+			want:   TxBroadcast,
+		},
+		{
+			name:   "broadcast → mined",
+			status: TxBroadcast,
+			setup: func(c *fakeTrackerClient, tx *types.Transaction) {
+				c.mine(tx, 10)
+				c.setHead(10)
+			},
+			want: TxMined,
+		},
+		{
+			name:   "broadcast → confirmed",
+			status: TxBroadcast,
+			setup: func(c *fakeTrackerClient, tx *types.Transaction) {
+				c.mine(tx, 10)
+				c.setHead(11)
+			},
+			want: TxConfirmed,
+		},
+		{
+			name:   "mined → failed",
+			status: TxMined,
+			setup: func(c *fakeTrackerClient, tx *types.Transaction) {
+				c.mine(tx, 10).Status = types.ReceiptStatusFailed
+				c.setHead(11)
+			},
+			want: TxFailed,
+		},
+		{
+			name:   "mined, block reorged → broadcast",
+			status: TxMined,
+			setup:  func(c *fakeTrackerClient, tx *types.Transaction) { c.pool[tx.Hash()] = true },
+			want:   TxBroadcast,
+		},
+		{
+			name:   "broadcast, nonce used by another tx → replaced",
+			status: TxBroadcast,
+			setup:  func(c *fakeTrackerClient, tx *types.Transaction) { c.nonce = tx.Nonce() + 1 },
+			want:   TxReplaced,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tracker, client, store, tx := newTracked(t, c.status, TrackerConfig{Confirmations: 2})
+			if c.setup != nil {
+				c.setup(client, tx)
+			}
+			record := trackedStatus(t, tracker, store)
+			if record.Status != c.want {
+				t.Errorf("status = %s, want %s", record.Status, c.want)
+			}
+			if len(client.sent) != c.sent {
+				t.Errorf("sent %d txs, want %d", len(client.sent), c.sent)
+			}
+		})
+	}
+}
+
+func TestTrackerDroppedRebroadcast(t *testing.T) {
+	tracker, client, store, tx := newTracked(t, TxBroadcast, TrackerConfig{DropTimeout: 50 * time.Millisecond})
+
+	// 刚发现不在交易池，不立即重新广播
+	trackedStatus(t, tracker, store)
+	if len(client.sent) != 0 {
+		t.Fatal("rebroadcast before DropTimeout")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	record := trackedStatus(t, tracker, store)
+	if len(client.sent) != 1 || client.sent[0].Hash() != tx.Hash() || record.Status != TxBroadcast {
+		t.Fatalf("sent %d txs, status %s", len(client.sent), record.Status)
+	}
+
+	// 重新广播后在交易池中，计时清零
+	client.unmine(tx)
+	trackedStatus(t, tracker, store)
+	if len(client.sent) != 1 {
+		t.Fatal("rebroadcast while in pool")
+	}
+}
+
+func TestTrackerReorg(t *testing.T) {
+	tracker, client, store, tx := newTracked(t, TxBroadcast, TrackerConfig{Confirmations: 3})
+
+	client.mine(tx, 10)
+	client.setHead(10)
+	if record := trackedStatus(t, tracker, store); record.Status != TxMined || record.BlockNumber != 10 {
+		t.Fatalf("status %s block %d", record.Status, record.BlockNumber)
+	}
+
+	// 区块被重组，交易回到交易池
+	client.unmine(tx)
+	if record := trackedStatus(t, tracker, store); record.Status != TxBroadcast || record.BlockNumber != 0 {
+		t.Fatalf("after reorg: status %s block %d", record.Status, record.BlockNumber)
+	}
+
+	client.mine(tx, 11)
+	client.setHead(13)
+	if record := trackedStatus(t, tracker, store); record.Status != TxConfirmed || record.BlockNumber != 11 {
+		t.Fatalf("re-mined: status %s block %d", record.Status, record.BlockNumber)
+	}
+}
+
+// bumpingStore 在 Tracker 读取记录之后执行 bump，模拟出账进程同时保存加速版本
+type bumpingStore struct {
+	TxStore
+	bump func()
+}
+
+func (s *bumpingStore) ListPending(ctx context.Context, chainID uint64) ([]*TxRecord, error) {
+	records, err := s.TxStore.ListPending(ctx, chainID)
+	if s.bump != nil {
+		s.bump()
+		s.bump = nil
+	}
+	return records, err
+}
+
+func TestTrackerConcurrentBump(t *testing.T) {
+	ctx := context.Background()
+	tracker, client, store, tx := newTracked(t, TxBroadcast, TrackerConfig{Confirmations: 1})
+	bumped, _ := signedTx(t, tx.Nonce(), 2)
+	tracker.store = &bumpingStore{TxStore: store, bump: func() {
+		record, _ := store.Get(ctx, "w-1")
+		if err := record.addSigned(bumped); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+		client.mine(bumped, 10)
+		client.setHead(10)
+		client.nonce = tx.Nonce() + 1
+	}}
+
+	// 读到的旧记录只有原版本：查不到收据、nonce 已被占用，但不能据此标记为 replaced
+	record := trackedStatus(t, tracker, store)
+	if record.Status != TxBroadcast || len(record.RawTxs) != 2 {
+		t.Fatalf("status %s with %d versions, want broadcast with the bump kept", record.Status, len(record.RawTxs))
+	}
+
+	// 下一轮读到加速版本
+	record = trackedStatus(t, tracker, store)
+	if record.Status != TxConfirmed || record.TxHash != bumped.Hash() {
+		t.Fatalf("status %s tx %s, want confirmed bump", record.Status, record.TxHash.Hex())
+	}
+}
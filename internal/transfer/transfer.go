@@ -2,34 +2,95 @@ package transfer
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"errors"
 	"fmt"
+	"log"
 	"math/big"
+	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"wallet/pkg/gas"
+	"wallet/pkg/rpcpool"
+	"wallet/pkg/signer"
 )
 
+// Client 转账需要的链上操作
+// *ethclient.Client 和 *rpcpool.Pool 都实现了该接口
+type Client interface {
+	gas.Client
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	ReceiptClient
+}
+
+// maxNonceRetries nonce 冲突时最多重新分配的次数
+const maxNonceRetries = 3
+
 // Transfer 转账管理器
 type Transfer struct {
-	client *ethclient.Client
+	client Client
+
+	mu     sync.Mutex
+	nonces *NonceManager
+	wait   WaitConfig
+	bump   BumpPolicy
+	txs    TxStore
+
+	decimals  map[common.Address]uint8 // 代币精度缓存
+	disperse  *common.Address          // 批量发放合约
+	errorABIs []abi.ABI                // 解码自定义 revert error
 }
 
 // Request 转账请求
 type Request struct {
-	From       common.Address
-	PrivateKey *ecdsa.PrivateKey
-	To         common.Address
-	Amount     *big.Int // Wei
-	Speed      gas.Speed
-	Data       []byte // 可选，合约调用数据
+	ID      string // 业务单号（提现单号），用于出账记录去重和查询；为空时自动生成
+	From    common.Address
+	Signer  signer.Signer // 签名器（本地私钥、keystore 或远程签名服务），地址必须与 From 一致
+	To      common.Address
+	Token   *common.Address // ERC-20 代币合约，nil 表示原生币转账
+	Amount  *big.Int        // 最小单位：原生币为 Wei，代币按合约 decimals（见 ParseUnits）
+	SendMax bool            // 转出全部余额（忽略 Amount）：原生币自动扣除最高 gas 费用，且不自动加速
+	Speed   gas.Speed
+	Data    []byte // 可选，合约调用数据（代币转账时不能设置）
+}
+
+// txCall 实际发出的交易内容
+// 代币转账发往代币合约，金额为 0，Data 为 transfer(to, amount)
+type txCall struct {
+	to          common.Address
+	value       *big.Int
+	data        []byte
+	returnsBool bool // 代币 transfer，模拟时检查返回值
+}
+
+// call 按请求组装交易内容
+func (req *Request) call() (*txCall, error) {
+	if req.Token == nil {
+		return &txCall{to: req.To, value: req.Amount, data: req.Data}, nil
+	}
+	if len(req.Data) > 0 {
+		return nil, fmt.Errorf("代币转账不能指定 Data")
+	}
+	data, err := EncodeTokenTransfer(req.To, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	return &txCall{to: *req.Token, value: new(big.Int), data: data, returnsBool: true}, nil
 }
 
 // Result 转账结果
 type Result struct {
+	ID          string // 出账记录 ID
 	TxHash      common.Hash
 	BlockNumber uint64
 	GasUsed     uint64
@@ -37,92 +98,444 @@ type Result struct {
 }
 
 // New 创建转账管理器
-func New(rpcURL string) (*Transfer, error) {
-	client, err := ethclient.Dial(rpcURL)
+// 传入多个 RPC 地址时自动故障切换
+func New(rpcURLs ...string) (*Transfer, error) {
+	pool, err := rpcpool.Dial(context.Background(), rpcURLs, rpcpool.Config{})
 	if err != nil {
-		return nil, fmt.Errorf("connect to rpc: %w", err)
+		return nil, err
 	}
 
-	return &Transfer{client: client}, nil
+	return &Transfer{client: pool}, nil
+}
+
+// NewWithClient 使用已有客户端创建转账管理器（例如与扫块器共享的连接池）
+func NewWithClient(client Client) *Transfer {
+	return &Transfer{client: client}
+}
+
+// SetNonceManager 设置 nonce 管理器（例如使用文件存储持久化）
+// 未设置时首次转账自动创建只保存在内存的管理器
+func (t *Transfer) SetNonceManager(m *NonceManager) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nonces = m
+}
+
+// SetWaitConfig 设置 Execute 等待确认的方式（确认数、超时等）
+func (t *Transfer) SetWaitConfig(cfg WaitConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.wait = cfg
+}
+
+// SetTxStore 设置出账交易存储
+// 设置后每笔转账在广播前落盘，进程中断后由 worker 的 Tracker 继续跟踪
+func (t *Transfer) SetTxStore(store TxStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.txs = store
+}
+
+// nonceManager 当前链的 nonce 管理器
+func (t *Transfer) nonceManager(chainID *big.Int) *NonceManager {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.nonces == nil {
+		t.nonces = NewNonceManager(t.client, chainID.Uint64(), nil)
+	}
+	return t.nonces
 }
 
 // Execute 执行转账
 func (t *Transfer) Execute(ctx context.Context, req Request) (*Result, error) {
-	// 1. 验证私钥和地址匹配
-	publicKey := req.PrivateKey.Public().(*ecdsa.PublicKey)
-	derivedAddr := crypto.PubkeyToAddress(*publicKey)
-	if derivedAddr != req.From {
-		return nil, fmt.Errorf("私钥和发送地址不匹配")
+	// 1. 验证签名器和地址匹配
+	if req.Signer == nil {
+		return nil, fmt.Errorf("未设置签名器")
+	}
+	if req.Signer.Address() != req.From {
+		return nil, fmt.Errorf("签名器和发送地址不匹配")
 	}
 
-	// 2. 检查余额
-	balance, err := t.client.BalanceAt(ctx, req.From, nil)
-	if err != nil {
-		return nil, fmt.Errorf("获取余额失败: %w", err)
+	if !req.SendMax && (req.Amount == nil || req.Amount.Sign() < 0) {
+		return nil, fmt.Errorf("转账金额无效")
 	}
 
-	if balance.Cmp(req.Amount) < 0 {
-		return nil, fmt.Errorf("余额不足: 需要 %s, 当前 %s",
-			weiToEth(req.Amount), weiToEth(balance))
+	// 2. 检查代币余额（余额不足时估算 gas 只会得到 revert，先检查）
+	if req.Token != nil {
+		if err := t.checkTokenBalance(ctx, &req); err != nil {
+			return nil, err
+		}
+	} else if req.SendMax {
+		// 金额要等算出 gas 费用后才能确定，先按 0 估算（普通转账的 gas 与金额无关）
+		req.Amount = new(big.Int)
 	}
 
-	// 3. 获取 nonce
-	nonce, err := t.client.PendingNonceAt(ctx, req.From)
+	// 3. 组装交易内容
+	call, err := req.call()
 	if err != nil {
-		return nil, fmt.Errorf("获取 nonce 失败: %w", err)
+		return nil, err
+	}
+
+	// 4. 模拟执行（会 revert 时直接返回原因，而不是估算 gas 时的笼统错误）
+	if err := t.simulateBeforeSend(ctx, req.From, call); err != nil {
+		return nil, err
 	}
 
-	// 4. 估算 gas
+	// 5. 估算 gas
 	params, err := gas.SuggestGasParams(
 		ctx,
 		t.client,
 		req.From,
-		&req.To,
-		req.Amount,
-		req.Data,
+		&call.to,
+		call.value,
+		call.data,
 		req.Speed,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("估算 gas 失败: %w", err)
 	}
 
-	// 5. 获取链 ID
+	// 6. 检查原生币余额：金额 + 最高 gas 费用
+	if err := t.checkBalance(ctx, &req, call, params); err != nil {
+		return nil, err
+	}
+
+	// 7. 获取链 ID
 	chainID, err := t.client.ChainID(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("获取链 ID 失败: %w", err)
 	}
 
-	// 6. 创建交易
-	tx := gas.CreateTransaction(nonce, &req.To, req.Amount, req.Data, params, chainID)
-
-	// 7. 签名
-	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), req.PrivateKey)
+	// 8. 记录出账交易
+	record, err := t.createRecord(ctx, req, call, chainID)
 	if err != nil {
-		return nil, fmt.Errorf("签名失败: %w", err)
+		return nil, err
 	}
 
-	// 8. 发送交易
-	if err := t.client.SendTransaction(ctx, signedTx); err != nil {
-		return nil, fmt.Errorf("发送交易失败: %w", err)
+	// 9. 分配 nonce、签名并发送（签名后、广播前落盘）
+	signedTx, err := t.send(ctx, t.nonceManager(chainID), req, call, params, chainID, record)
+	if err != nil {
+		return nil, err
 	}
 
-	// 9. 等待确认（可选）
-	receipt, err := t.waitForReceipt(ctx, signedTx.Hash())
+	// 10. 等待确认
+	// 超时、被丢弃时记录保持 broadcast，由 Tracker 继续跟踪和重新广播
+	receipt, err := t.waitForReceipt(ctx, signedTx, req, record)
+	if errors.Is(err, ErrTxReplaced) {
+		record.Error = err.Error()
+		t.updateRecord(ctx, record, TxReplaced)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("等待确认失败: %w", err)
 	}
 
+	record.setReceipt(receipt)
+	success := receipt.Status == types.ReceiptStatusSuccessful
+	if success && record.cancelled(receipt.TxHash) {
+		success = false
+		record.Error = errTxCancelled
+	}
+	if success && req.Token != nil && !tokenTransferred(receipt, *req.Token, req.From, req.To, req.Amount) {
+		success = false
+		record.Error = errNoTransferLog
+	}
+	if success {
+		t.updateRecord(ctx, record, TxMined, TxConfirmed)
+	} else {
+		t.updateRecord(ctx, record, TxMined, TxFailed)
+	}
+
 	return &Result{
-		TxHash:      signedTx.Hash(),
+		ID:          record.ID,
+		TxHash:      receipt.TxHash, // 自动加速后为替换交易的哈希
 		BlockNumber: receipt.BlockNumber.Uint64(),
 		GasUsed:     receipt.GasUsed,
-		Success:     receipt.Status == types.ReceiptStatusSuccessful,
+		Success:     success,
 	}, nil
 }
 
-// waitForReceipt 等待交易确认
-func (t *Transfer) waitForReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
-	return t.client.TransactionReceipt(ctx, txHash)
+// send 分配 nonce、签名并广播
+// nonce 已被使用时与链上重新同步后换一个 nonce 重试
+func (t *Transfer) send(ctx context.Context, nonces *NonceManager, req Request, call *txCall, params *gas.GasParams, chainID *big.Int, record *TxRecord) (*types.Transaction, error) {
+	for attempt := 0; ; attempt++ {
+		nonce, err := nonces.Acquire(ctx, req.From)
+		if err != nil {
+			return nil, fmt.Errorf("获取 nonce 失败: %w", err)
+		}
+
+		tx := gas.CreateTransaction(nonce, &call.to, call.value, call.data, params, chainID)
+		signedTx, err := req.Signer.SignTx(ctx, tx, chainID)
+		if err != nil {
+			nonces.Release(req.From, nonce)
+			record.Error = err.Error()
+			t.updateRecord(ctx, record, TxFailed)
+			return nil, fmt.Errorf("签名失败: %w", err)
+		}
+		if err := t.recordSigned(ctx, record, signedTx); err != nil {
+			// 没有落盘不广播，否则中断后无法追踪
+			nonces.Release(req.From, nonce)
+			return nil, err
+		}
+
+		err = t.client.SendTransaction(ctx, signedTx)
+		if err == nil || isAlreadyKnown(err) {
+			nonces.Commit(req.From, nonce)
+			t.updateRecord(ctx, record, TxBroadcast)
+			return signedTx, nil
+		}
+
+		var rpcErr rpc.Error
+		switch {
+		case isNonceTooLow(err):
+			// 这个 nonce 已经被占用，不能归还
+			nonces.Commit(req.From, nonce)
+			if serr := nonces.Resync(ctx, req.From); serr != nil {
+				return nil, fmt.Errorf("同步 nonce 失败: %w", serr)
+			}
+			if attempt < maxNonceRetries {
+				continue
+			}
+		case isReplacementUnderpriced(err):
+			// 交易池里已有同 nonce 的另一笔交易（外部发送或被误判为空洞），
+			// 这个 nonce 不能归还，也不能自动覆盖对方
+			nonces.Commit(req.From, nonce)
+		case errors.As(err, &rpcErr):
+			// 节点明确拒绝，交易没有进入交易池
+			nonces.Release(req.From, nonce)
+		default:
+			// 网络错误等，交易可能已经广播出去，不归还 nonce
+			// 记录保持 signed，由 Tracker 重新广播
+			nonces.Commit(req.From, nonce)
+			return nil, fmt.Errorf("发送交易失败: %w", err)
+		}
+		record.Error = err.Error()
+		t.updateRecord(ctx, record, TxFailed)
+		return nil, fmt.Errorf("发送交易失败: %w", err)
+	}
+}
+
+// createRecord 创建出账记录，业务单号已存在时拒绝重复发送
+func (t *Transfer) createRecord(ctx context.Context, req Request, call *txCall, chainID *big.Int) (*TxRecord, error) {
+	now := time.Now()
+	record := &TxRecord{
+		ID:        req.ID,
+		ChainID:   chainID.Uint64(),
+		From:      req.From,
+		To:        req.To,
+		Token:     req.Token,
+		Value:     req.Amount,
+		Data:      call.data,
+		Status:    TxCreated,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if record.ID == "" {
+		record.ID = newTxID()
+	}
+
+	store := t.txStore()
+	if store == nil {
+		return record, nil
+	}
+	existing, err := store.Get(ctx, record.ID)
+	if err != nil {
+		return nil, fmt.Errorf("查询出账记录失败: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("出账记录 %s 已存在（状态 %s）", existing.ID, existing.Status)
+	}
+	if err := store.Save(ctx, record); err != nil {
+		return nil, fmt.Errorf("保存出账记录失败: %w", err)
+	}
+	return record, nil
+}
+
+// recordSigned 记录签名后的交易并落盘
+// nonce 冲突重试时换了 nonce，之前的版本作废
+func (t *Transfer) recordSigned(ctx context.Context, record *TxRecord, tx *types.Transaction) error {
+	if record.Status == TxCreated {
+		if err := record.transition(TxSigned); err != nil {
+			return err
+		}
+	}
+	record.RawTxs = nil
+	if err := record.addSigned(tx); err != nil {
+		return err
+	}
+	if store := t.txStore(); store != nil {
+		if err := store.Save(ctx, record); err != nil {
+			return fmt.Errorf("保存出账记录失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// updateRecord 依次变更出账记录状态并落盘
+// 广播之后的状态保存失败只记录日志，Tracker 会根据链上状态补齐
+func (t *Transfer) updateRecord(ctx context.Context, record *TxRecord, statuses ...TxStatus) {
+	for _, status := range statuses {
+		if err := record.transition(status); err != nil {
+			log.Printf("更新出账记录失败: %v", err)
+			return
+		}
+	}
+	if store := t.txStore(); store != nil {
+		if err := record.mergeVersions(t.storedVersions(ctx, record)); err != nil {
+			log.Printf("合并出账记录 %s 失败: %v", record.ID, err)
+		}
+		if err := store.Save(ctx, record); err != nil {
+			log.Printf("保存出账记录 %s 失败: %v", record.ID, err)
+		}
+	}
+}
+
+// storedVersions 存储中有而 record 没有的版本（等待期间 SpeedUp / Cancel 另外加入的），
+// 保存前先补上，避免覆盖掉
+func (t *Transfer) storedVersions(ctx context.Context, record *TxRecord) []hexutil.Bytes {
+	store := t.txStore()
+	if store == nil {
+		return nil
+	}
+	stored, err := store.Get(ctx, record.ID)
+	if err != nil || stored == nil {
+		return nil
+	}
+	var raws []hexutil.Bytes
+	for _, raw := range stored.RawTxs {
+		if !record.hasRaw(raw) {
+			raws = append(raws, raw)
+		}
+	}
+	return raws
+}
+
+func (t *Transfer) txStore() TxStore {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.txs
+}
+
+// waitForReceipt 等待交易确认（按 BumpPolicy 自动加速）
+func (t *Transfer) waitForReceipt(ctx context.Context, tx *types.Transaction, req Request, record *TxRecord) (*types.Receipt, error) {
+	t.mu.Lock()
+	cfg, policy := t.wait, t.bump
+	t.mu.Unlock()
+
+	// 原生币 SendMax 的金额 = 余额 - 最高 gas 费用，加价后余额不够支付，节点会拒绝替换交易
+	if req.Token == nil && req.SendMax && policy.After > 0 {
+		log.Printf("转出全部余额的交易不自动加速: tx=%s", tx.Hash().Hex())
+		policy.After = 0
+	}
+
+	// 总超时覆盖加速前后的整个等待过程
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+		cfg.Timeout = 0
+	}
+	// 出账记录保存各个已发出的版本，加速交易广播前先落盘，保存失败则不广播
+	// 回滚时 txs 去掉未能广播的加速版本，它已在 record 中，不会被当作其他调用方的版本补回
+	save := func(txs []*types.Transaction) error {
+		others := t.storedVersions(ctx, record)
+		next := *record
+		next.RawTxs = nil
+		for _, tx := range txs {
+			if err := next.addSigned(tx); err != nil {
+				return err
+			}
+		}
+		if err := next.mergeVersions(others); err != nil {
+			return err
+		}
+		next.UpdatedAt = time.Now()
+		if store := t.txStore(); store != nil {
+			if err := store.Save(ctx, &next); err != nil {
+				return fmt.Errorf("保存出账记录失败: %w", err)
+			}
+		}
+		*record = next
+		return nil
+	}
+	receipt, err := t.waitWithBump(ctx, NewWaiter(t.client, cfg), tx, req.Signer, policy, save)
+	if others := t.storedVersions(ctx, record); errors.Is(err, ErrTxReplaced) && len(others) > 0 {
+		// 等待期间通过 SpeedUp / Cancel 发出了新版本，按全部版本重新确认
+		if err := record.mergeVersions(others); err != nil {
+			return nil, err
+		}
+		txs, err := record.Transactions()
+		if err != nil {
+			return nil, err
+		}
+		return NewWaiter(t.client, cfg).Wait(ctx, txs...)
+	}
+	return receipt, err
+}
+
+// checkTokenBalance 代币余额是否足够，SendMax 时金额设为全部代币余额
+func (t *Transfer) checkTokenBalance(ctx context.Context, req *Request) error {
+	balance, err := t.TokenBalance(ctx, *req.Token, req.From)
+	if err != nil {
+		return fmt.Errorf("获取代币余额失败: %w", err)
+	}
+	if req.SendMax {
+		if balance.Sign() == 0 {
+			return fmt.Errorf("代币余额为 0")
+		}
+		req.Amount = balance
+		return nil
+	}
+	if balance.Cmp(req.Amount) >= 0 {
+		return nil
+	}
+
+	decimals, err := t.TokenDecimals(ctx, *req.Token)
+	if err != nil {
+		return fmt.Errorf("代币余额不足: 需要 %s, 当前 %s", req.Amount, balance)
+	}
+	return fmt.Errorf("代币余额不足: 需要 %s, 当前 %s",
+		FormatUnits(req.Amount, decimals), FormatUnits(balance, decimals))
+}
+
+// checkBalance 原生币余额是否够付 金额 + 最高 gas 费用
+// 节点按 value + gasLimit * gasFeeCap 检查余额，只比较金额会在广播时才失败。
+// 原生币 SendMax 时金额设为 余额 - 最高 gas 费用（实际 gas 费用更低，差额留在地址上）
+func (t *Transfer) checkBalance(ctx context.Context, req *Request, call *txCall, params *gas.GasParams) error {
+	balance, err := t.client.BalanceAt(ctx, req.From, nil)
+	if err != nil {
+		return fmt.Errorf("获取余额失败: %w", err)
+	}
+	fee := maxGasCost(params)
+
+	if req.Token == nil && req.SendMax {
+		amount := new(big.Int).Sub(balance, fee)
+		if amount.Sign() <= 0 {
+			return fmt.Errorf("余额不足以支付 gas: 需要 %s, 当前 %s", weiToEth(fee), weiToEth(balance))
+		}
+		req.Amount = amount
+		call.value = amount
+		return nil
+	}
+
+	need := new(big.Int).Add(call.value, fee)
+	if balance.Cmp(need) >= 0 {
+		return nil
+	}
+	if req.Token != nil {
+		// 代币转账的 gas 用原生币支付
+		return fmt.Errorf("余额不足以支付 gas: 需要 %s, 当前 %s", weiToEth(fee), weiToEth(balance))
+	}
+	return fmt.Errorf("余额不足: 需要 %s（金额 %s + 最高 gas 费 %s）, 当前 %s",
+		weiToEth(need), weiToEth(call.value), weiToEth(fee), weiToEth(balance))
+}
+
+// maxGasCost 最坏情况下的 gas 费用：GasLimit * GasFeeCap（Legacy 为 GasPrice）
+func maxGasCost(params *gas.GasParams) *big.Int {
+	price := params.GasFeeCap
+	if params.IsLegacy {
+		price = params.GasPrice
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(params.GasLimit), price)
 }
 
 // GetBalance 查询余额
@@ -132,8 +545,8 @@ func (t *Transfer) GetBalance(ctx context.Context, addr common.Address) (*big.In
 
 // Close 关闭连接
 func (t *Transfer) Close() {
-	if t.client != nil {
-		t.client.Close()
+	if c, ok := t.client.(interface{ Close() }); ok {
+		c.Close()
 	}
 }
 
@@ -0,0 +1,173 @@
+package transfer
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"wallet/pkg/gas"
+	"wallet/pkg/signer"
+)
+
+// newSimulated 创建模拟链并后台持续出块，返回的请求模板带有 100 ETH 的账户
+func newSimulated(t *testing.T) (*Transfer, Request) {
+	backend, key := newBackend(t)
+
+	// 关闭模拟链前等出块协程退出，否则 Close 与 Commit 并发
+	done, stopped := make(chan struct{}), make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				backend.Commit()
+			}
+		}
+	}()
+
+	tr := NewWithClient(backend.Client())
+	tr.SetWaitConfig(WaitConfig{PollInterval: 50 * time.Millisecond, Timeout: 30 * time.Second})
+	return tr, Request{From: crypto.PubkeyToAddress(key.PublicKey), Signer: signer.NewLocal(key)}
+}
+
+func TestMaxGasCost(t *testing.T) {
+	gwei := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.GWei)) }
+
+	legacy := maxGasCost(&gas.GasParams{GasLimit: 21000, GasPrice: gwei(10), IsLegacy: true})
+	if legacy.Cmp(new(big.Int).Mul(big.NewInt(21000), gwei(10))) != 0 {
+		t.Errorf("legacy = %s", legacy)
+	}
+	// EIP-1559 按 gasFeeCap 计算，不是 baseFee + tip
+	dynamic := maxGasCost(&gas.GasParams{GasLimit: 50000, GasTipCap: gwei(2), GasFeeCap: gwei(30)})
+	if dynamic.Cmp(new(big.Int).Mul(big.NewInt(50000), gwei(30))) != 0 {
+		t.Errorf("1559 = %s", dynamic)
+	}
+}
+
+func TestCheckBalance(t *testing.T) {
+	backend, key := newBackend(t)
+	tr := NewWithClient(backend.Client())
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	balance := new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))
+
+	ether := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.Ether)) }
+	fees := &gas.GasParams{GasLimit: 21000, GasTipCap: big.NewInt(params.GWei), GasFeeCap: big.NewInt(100 * params.GWei)}
+	fee := maxGasCost(fees)
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+
+	cases := []struct {
+		name    string
+		req     Request
+		value   *big.Int
+		fees    *gas.GasParams
+		wantErr string
+	}{
+		{name: "enough", value: ether(1), fees: fees},
+		{name: "exactly amount + max fee", value: new(big.Int).Sub(balance, fee), fees: fees},
+		{name: "amount ok but not gas", value: new(big.Int).Sub(balance, big.NewInt(1)), fees: fees, wantErr: "余额不足"},
+		{name: "legacy", value: ether(99), fees: &gas.GasParams{GasLimit: 21000, GasPrice: big.NewInt(params.GWei), IsLegacy: true}},
+		{name: "token gas only", req: Request{Token: &token}, value: new(big.Int), fees: fees},
+		{
+			name:    "token gas too high",
+			req:     Request{Token: &token},
+			value:   new(big.Int),
+			fees:    &gas.GasParams{GasLimit: 1 << 40, GasFeeCap: ether(1)},
+			wantErr: "余额不足以支付 gas",
+		},
+		{
+			name:    "send max gas too high",
+			req:     Request{SendMax: true},
+			value:   new(big.Int),
+			fees:    &gas.GasParams{GasLimit: 1 << 40, GasFeeCap: ether(1)},
+			wantErr: "余额不足以支付 gas",
+		},
+	}
+	for _, c := range cases {
+		req := c.req
+		req.From = from
+		err := tr.checkBalance(context.Background(), &req, &txCall{value: c.value}, c.fees)
+		if c.wantErr == "" && err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+			t.Errorf("%s: err = %v, want %q", c.name, err, c.wantErr)
+		}
+	}
+
+	// SendMax 扣除最高 gas 费用
+	req := Request{From: from, SendMax: true}
+	call := &txCall{value: new(big.Int)}
+	if err := tr.checkBalance(context.Background(), &req, call, fees); err != nil {
+		t.Fatal(err)
+	}
+	want := new(big.Int).Sub(balance, fee)
+	if req.Amount.Cmp(want) != 0 || call.value.Cmp(want) != 0 {
+		t.Errorf("send max amount = %s / %s, want %s", req.Amount, call.value, want)
+	}
+}
+
+// versionStore 记录保存过的最多版本数
+type versionStore struct {
+	*FileTxStore
+	mu  sync.Mutex
+	max int
+}
+
+func (s *versionStore) Save(ctx context.Context, record *TxRecord) error {
+	s.mu.Lock()
+	s.max = max(s.max, len(record.RawTxs))
+	s.mu.Unlock()
+	return s.FileTxStore.Save(ctx, record)
+}
+
+func TestExecuteSendMax(t *testing.T) {
+	tr, sender := newSimulated(t)
+	file, err := NewFileTxStore(filepath.Join(t.TempDir(), "outbound.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &versionStore{FileTxStore: file}
+	tr.SetTxStore(store)
+	// 加速会因余额不足被拒绝，SendMax 不应自动加速
+	tr.SetBumpPolicy(BumpPolicy{After: time.Millisecond, Speed: gas.Fast})
+
+	to := common.HexToAddress("0x0000000000000000000000000000000000003000")
+	result, err := tr.Execute(context.Background(), Request{
+		ID:      "send-max",
+		From:    sender.From,
+		Signer:  sender.Signer,
+		To:      to,
+		SendMax: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success {
+		t.Fatal("send max failed")
+	}
+
+	record, _ := store.Get(context.Background(), "send-max")
+	if record.Status != TxConfirmed || store.max != 1 {
+		t.Errorf("record %s, bumped to %d versions", record.Status, store.max)
+	}
+	received, _ := tr.client.BalanceAt(context.Background(), to, nil)
+	left, _ := tr.client.BalanceAt(context.Background(), sender.From, nil)
+	if received.Cmp(record.Value) != 0 || left.Cmp(big.NewInt(params.Ether/100)) >= 0 {
+		t.Errorf("received %s, left %s", received, left)
+	}
+}
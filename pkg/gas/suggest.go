@@ -8,7 +8,6 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 // Speed 速度档位
@@ -29,10 +28,20 @@ type GasParams struct {
 	IsLegacy  bool     // 是否为 Legacy 交易类型
 }
 
+// Client 估算 gas 需要的链上查询
+// *ethclient.Client 和 *rpcpool.Pool 都实现了该接口
+type Client interface {
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+	ChainID(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
 // SuggestGasParams 自动填充 gas 参数（核心函数）
 // 参数:
 //   - ctx: 上下文
-//   - client: ETH 客户端
+//   - client: ETH 客户端（单节点或连接池）
 //   - from: 发送者地址
 //   - to: 接收者地址（可为 nil，表示合约创建）
 //   - value: 转账金额
@@ -40,7 +49,7 @@ type GasParams struct {
 //   - speed: 速度档位
 func SuggestGasParams(
 	ctx context.Context,
-	client *ethclient.Client,
+	client Client,
 	from common.Address,
 	to *common.Address,
 	value *big.Int,
@@ -60,13 +69,24 @@ func SuggestGasParams(
 	// +20% 缓冲
 	gasLimit = gasLimit * 120 / 100
 
-	// 2. 获取链 ID
+	params, err := SuggestFees(ctx, client, speed)
+	if err != nil {
+		return nil, err
+	}
+	params.GasLimit = gasLimit
+	return params, nil
+}
+
+// SuggestFees 只估算费用（不估算 gasLimit），用于替换已发送的交易等场景
+// 返回的 GasParams.GasLimit 为 0
+func SuggestFees(ctx context.Context, client Client, speed Speed) (*GasParams, error) {
+	// 1. 获取链 ID
 	chainID, err := client.ChainID(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("get chain ID failed: %w", err)
 	}
 
-	// 3. 获取最新费用建议
+	// 2. 获取最新费用建议
 	suggestedGasTipCap, err := client.SuggestGasTipCap(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("suggest gas tip cap failed: %w", err)
@@ -108,7 +128,6 @@ func SuggestGasParams(
 		gasPrice.Add(gasPrice, oneGwei)
 
 		return &GasParams{
-			GasLimit: gasLimit,
 			GasPrice: gasPrice,
 			IsLegacy: true,
 		}, nil
@@ -139,7 +158,6 @@ func SuggestGasParams(
 	}
 
 	return &GasParams{
-		GasLimit:  gasLimit,
 		GasTipCap: tip,
 		GasFeeCap: feeCap,
 		IsLegacy:  false,
@@ -0,0 +1,191 @@
+package rpcpool
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// 以下方法与 ethclient.Client 同名同签名，调用方可以直接替换
+
+// ChainID 链 ID
+func (p *Pool) ChainID(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, "eth_chainId", func(c *ethclient.Client) (*big.Int, error) {
+		return c.ChainID(ctx)
+	})
+}
+
+// BlockNumber 最新区块号
+func (p *Pool) BlockNumber(ctx context.Context) (uint64, error) {
+	return call(ctx, p, "eth_blockNumber", func(c *ethclient.Client) (uint64, error) {
+		return c.BlockNumber(ctx)
+	})
+}
+
+// BlockByNumber 按高度获取区块（number 为 nil 表示最新）
+func (p *Pool) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return call(ctx, p, "eth_getBlockByNumber", func(c *ethclient.Client) (*types.Block, error) {
+		return c.BlockByNumber(ctx, number)
+	})
+}
+
+// HeaderByNumber 按高度获取区块头
+func (p *Pool) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return call(ctx, p, "eth_getBlockByNumber", func(c *ethclient.Client) (*types.Header, error) {
+		return c.HeaderByNumber(ctx, number)
+	})
+}
+
+// HeaderByHash 按哈希获取区块头
+func (p *Pool) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return call(ctx, p, "eth_getBlockByHash", func(c *ethclient.Client) (*types.Header, error) {
+		return c.HeaderByHash(ctx, hash)
+	})
+}
+
+// BlockReceipts 区块内所有交易的收据
+func (p *Pool) BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	return call(ctx, p, "eth_getBlockReceipts", func(c *ethclient.Client) ([]*types.Receipt, error) {
+		return c.BlockReceipts(ctx, blockNrOrHash)
+	})
+}
+
+// TransactionByHash 按哈希查询交易
+func (p *Pool) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	var pending bool
+	tx, err := call(ctx, p, "eth_getTransactionByHash", func(c *ethclient.Client) (*types.Transaction, error) {
+		tx, isPending, err := c.TransactionByHash(ctx, hash)
+		pending = isPending
+		return tx, err
+	})
+	return tx, pending, err
+}
+
+// TransactionReceipt 交易收据
+func (p *Pool) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return call(ctx, p, "eth_getTransactionReceipt", func(c *ethclient.Client) (*types.Receipt, error) {
+		return c.TransactionReceipt(ctx, txHash)
+	})
+}
+
+// BalanceAt 账户余额
+func (p *Pool) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return call(ctx, p, "eth_getBalance", func(c *ethclient.Client) (*big.Int, error) {
+		return c.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+// NonceAt 账户在指定区块的 nonce
+func (p *Pool) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return call(ctx, p, "eth_getTransactionCount", func(c *ethclient.Client) (uint64, error) {
+		return c.NonceAt(ctx, account, blockNumber)
+	})
+}
+
+// PendingNonceAt 账户的 pending nonce
+func (p *Pool) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return call(ctx, p, "eth_getTransactionCount", func(c *ethclient.Client) (uint64, error) {
+		return c.PendingNonceAt(ctx, account)
+	})
+}
+
+// CodeAt 合约代码
+func (p *Pool) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return call(ctx, p, "eth_getCode", func(c *ethclient.Client) ([]byte, error) {
+		return c.CodeAt(ctx, account, blockNumber)
+	})
+}
+
+// PendingCodeAt pending 状态下的合约代码
+func (p *Pool) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return call(ctx, p, "eth_getCode", func(c *ethclient.Client) ([]byte, error) {
+		return c.PendingCodeAt(ctx, account)
+	})
+}
+
+// CallContract 执行 eth_call
+func (p *Pool) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return call(ctx, p, "eth_call", func(c *ethclient.Client) ([]byte, error) {
+		return c.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+// PendingCallContract 在 pending 状态上执行 eth_call
+func (p *Pool) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
+	return call(ctx, p, "eth_call", func(c *ethclient.Client) ([]byte, error) {
+		return c.PendingCallContract(ctx, msg)
+	})
+}
+
+// EstimateGas 估算 gas
+func (p *Pool) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return call(ctx, p, "eth_estimateGas", func(c *ethclient.Client) (uint64, error) {
+		return c.EstimateGas(ctx, msg)
+	})
+}
+
+// SuggestGasPrice 建议 gas 价格
+func (p *Pool) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, "eth_gasPrice", func(c *ethclient.Client) (*big.Int, error) {
+		return c.SuggestGasPrice(ctx)
+	})
+}
+
+// SuggestGasTipCap 建议小费
+func (p *Pool) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, "eth_maxPriorityFeePerGas", func(c *ethclient.Client) (*big.Int, error) {
+		return c.SuggestGasTipCap(ctx)
+	})
+}
+
+// FilterLogs 查询日志
+func (p *Pool) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return call(ctx, p, "eth_getLogs", func(c *ethclient.Client) ([]types.Log, error) {
+		return c.FilterLogs(ctx, q)
+	})
+}
+
+// SendTransaction 广播交易
+// 换节点重发同一笔已签名交易是安全的：前一个节点超时但实际已收到交易时，
+// 后一个节点会返回 already known，这种情况视为广播成功
+func (p *Pool) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	attempts := 0
+	err := p.do(ctx, "eth_sendRawTransaction", func(n *node) error {
+		attempts++
+		return n.eth.SendTransaction(ctx, tx)
+	})
+	if err != nil && attempts > 1 && isAlreadyKnown(err) {
+		return nil
+	}
+	return err
+}
+
+// isAlreadyKnown 交易已在节点交易池中（geth: already known，部分客户端: known transaction）
+func isAlreadyKnown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
+
+// CallContext 原始 JSON-RPC 调用（debug_trace 等 ethclient 未封装的方法）
+func (p *Pool) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return p.do(ctx, method, func(n *node) error {
+		return n.rpc.CallContext(ctx, result, method, args...)
+	})
+}
+
+// BatchCallContext 批量 JSON-RPC 调用
+// 只有整批请求失败才换节点，单个请求的错误写在 BatchElem.Error 里
+func (p *Pool) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	return p.do(ctx, "batch", func(n *node) error {
+		for i := range b {
+			b[i].Error = nil
+		}
+		return n.rpc.BatchCallContext(ctx, b)
+	})
+}
@@ -0,0 +1,373 @@
+package rpcpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Strategy 节点选择策略
+type Strategy string
+
+const (
+	Priority   Strategy = "priority"    // 按配置顺序，优先用第一个健康节点（默认）
+	RoundRobin Strategy = "round_robin" // 健康节点轮询，分摊请求量
+	Fastest    Strategy = "fastest"     // 按健康评分（延迟 + 错误率）排序
+)
+
+// Config 连接池配置
+type Config struct {
+	Strategy            Strategy
+	MaxLag              uint64        // 落后最高节点超过该区块数视为不健康（默认 5）
+	MaxFailures         int           // 连续失败多少次后剔除（默认 3）
+	EjectDuration       time.Duration // 剔除时长，到期后重新参与选择（默认 30s）
+	HealthCheckInterval time.Duration // 健康检查间隔，检查链头高度和延迟（默认 10s）
+
+	// OnError 节点调用失败时回调（可选，用于监控打点）
+	OnError func(method, endpoint string, err error)
+}
+
+// Pool 多节点 RPC 客户端
+//
+// 每次调用按策略选出候选节点依次尝试，节点故障（网络错误、限流、5xx、
+// 方法不支持等）时自动切换到下一个；交易执行失败、nonce 过低这类
+// 业务错误直接返回，不会换节点重试。
+// 后台健康检查定期比较各节点的链头高度，落后太多的节点暂不使用；
+// 所有节点都不健康时仍会尝试，避免整体不可用。
+type Pool struct {
+	cfg   Config
+	nodes []*node
+	next  atomic.Uint64 // 轮询计数
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// node 单个 RPC 节点及其健康状态
+type node struct {
+	url string
+	rpc *rpc.Client
+	eth *ethclient.Client
+
+	mu           sync.Mutex
+	latency      time.Duration // 请求延迟（指数移动平均）
+	errRate      float64       // 错误率（指数移动平均）
+	failures     int           // 连续失败次数
+	head         uint64        // 最近一次健康检查的链头
+	lagging      bool          // 链头落后
+	ejectedUntil time.Time     // 剔除到期时间
+}
+
+// NodeStats 节点状态快照
+type NodeStats struct {
+	URL       string
+	Healthy   bool
+	Lagging   bool
+	Head      uint64
+	Latency   time.Duration
+	ErrorRate float64
+}
+
+// ewmaWeight 新样本权重
+const ewmaWeight = 0.2
+
+// Dial 连接所有节点并启动后台健康检查
+// 部分节点连接失败只记录日志，全部失败才返回错误
+func Dial(ctx context.Context, urls []string, cfg Config) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no rpc url")
+	}
+	if cfg.Strategy == "" {
+		cfg.Strategy = Priority
+	}
+	if cfg.MaxLag == 0 {
+		cfg.MaxLag = 5
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.EjectDuration <= 0 {
+		cfg.EjectDuration = 30 * time.Second
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = 10 * time.Second
+	}
+
+	p := &Pool{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	var lastErr error
+	for _, url := range urls {
+		client, err := rpc.DialContext(ctx, url)
+		if err != nil {
+			log.Printf("连接 RPC 节点失败 (%s): %v", url, err)
+			lastErr = err
+			continue
+		}
+		p.nodes = append(p.nodes, &node{url: url, rpc: client, eth: ethclient.NewClient(client)})
+	}
+	if len(p.nodes) == 0 {
+		return nil, fmt.Errorf("connect to rpc: %w", lastErr)
+	}
+
+	go p.healthLoop()
+	return p, nil
+}
+
+// Close 停止健康检查并关闭所有连接
+func (p *Pool) Close() {
+	select {
+	case <-p.stop:
+		return
+	default:
+	}
+	close(p.stop)
+	<-p.done
+	for _, n := range p.nodes {
+		n.rpc.Close()
+	}
+}
+
+// Stats 所有节点的状态快照
+func (p *Pool) Stats() []NodeStats {
+	now := time.Now()
+	stats := make([]NodeStats, 0, len(p.nodes))
+	for _, n := range p.nodes {
+		n.mu.Lock()
+		stats = append(stats, NodeStats{
+			URL:       n.url,
+			Healthy:   n.healthy(now),
+			Lagging:   n.lagging,
+			Head:      n.head,
+			Latency:   n.latency,
+			ErrorRate: n.errRate,
+		})
+		n.mu.Unlock()
+	}
+	return stats
+}
+
+// candidates 本次调用依次尝试的节点：健康节点按策略排序，不健康节点垫底
+func (p *Pool) candidates() []*node {
+	now := time.Now()
+	healthy := make([]*node, 0, len(p.nodes))
+	var unhealthy []*node
+	for _, n := range p.nodes {
+		n.mu.Lock()
+		ok := n.healthy(now)
+		n.mu.Unlock()
+		if ok {
+			healthy = append(healthy, n)
+		} else {
+			unhealthy = append(unhealthy, n)
+		}
+	}
+
+	switch p.cfg.Strategy {
+	case RoundRobin:
+		if len(healthy) > 1 {
+			k := int(p.next.Add(1) % uint64(len(healthy)))
+			healthy = append(healthy[k:], healthy[:k]...)
+		}
+	case Fastest:
+		scores := make(map[*node]float64, len(healthy))
+		for _, n := range healthy {
+			scores[n] = n.score()
+		}
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return scores[healthy[i]] < scores[healthy[j]]
+		})
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// do 按候选顺序调用，节点故障时切换到下一个
+func (p *Pool) do(ctx context.Context, method string, fn func(n *node) error) error {
+	var lastErr error
+	for _, n := range p.candidates() {
+		start := time.Now()
+		err := fn(n)
+		elapsed := time.Since(start)
+
+		switch {
+		case err == nil:
+			n.record(elapsed, nil, p.cfg)
+			return nil
+
+		case errors.Is(err, ethereum.NotFound):
+			// 节点可能只是还没同步到，换下一个节点查，但不算节点故障
+			n.record(elapsed, nil, p.cfg)
+			lastErr = err
+			continue
+
+		case ctx.Err() != nil:
+			return err
+
+		case !isNodeError(err):
+			// 业务错误（交易回滚、nonce 过低等），换节点结果也一样
+			n.record(elapsed, nil, p.cfg)
+			return err
+		}
+
+		n.record(elapsed, err, p.cfg)
+		lastErr = err
+		log.Printf("RPC %s 调用失败 (%s): %v", method, n.url, err)
+		if p.cfg.OnError != nil {
+			p.cfg.OnError(method, n.url, err)
+		}
+	}
+
+	if errors.Is(lastErr, ethereum.NotFound) {
+		return lastErr
+	}
+	return fmt.Errorf("%s: all rpc endpoints failed: %w", method, lastErr)
+}
+
+// call 带返回值的 do
+func call[T any](ctx context.Context, p *Pool, method string, fn func(c *ethclient.Client) (T, error)) (T, error) {
+	var out T
+	err := p.do(ctx, method, func(n *node) error {
+		var err error
+		out, err = fn(n.eth)
+		return err
+	})
+	return out, err
+}
+
+// isNodeError 是否属于节点自身的故障（换节点可能成功）
+func isNodeError(err error) bool {
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return true // 限流、鉴权失败、5xx 都是节点问题
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.ErrorCode() {
+		case -32601, // method not found
+			-32005, // limit exceeded
+			-32603: // internal error
+			return true
+		}
+		return false
+	}
+
+	// 连接失败、超时、响应解析失败等
+	return true
+}
+
+// healthLoop 定期检查所有节点的链头高度和延迟
+func (p *Pool) healthLoop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p.checkHealth()
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth 并发查询所有节点的链头，标记落后节点
+func (p *Pool) checkHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.HealthCheckInterval)
+	defer cancel()
+
+	heads := make([]uint64, len(p.nodes))
+	var wg sync.WaitGroup
+	for i, n := range p.nodes {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+			start := time.Now()
+			head, err := n.eth.BlockNumber(ctx)
+			n.record(time.Since(start), err, p.cfg)
+			if err == nil {
+				heads[i] = head
+			} else if p.cfg.OnError != nil {
+				p.cfg.OnError("eth_blockNumber", n.url, err)
+			}
+		}(i, n)
+	}
+	wg.Wait()
+
+	var best uint64
+	for _, head := range heads {
+		if head > best {
+			best = head
+		}
+	}
+
+	for i, n := range p.nodes {
+		n.mu.Lock()
+		wasLagging := n.lagging
+		if heads[i] > 0 {
+			n.head = heads[i]
+			n.lagging = best-heads[i] > p.cfg.MaxLag
+		}
+		if n.lagging != wasLagging {
+			if n.lagging {
+				log.Printf("RPC 节点落后 (%s): head=%d, best=%d", n.url, n.head, best)
+			} else {
+				log.Printf("RPC 节点恢复同步 (%s): head=%d", n.url, n.head)
+			}
+		}
+		n.mu.Unlock()
+	}
+}
+
+// record 记录一次请求结果，连续失败达到上限时剔除节点
+func (n *node) record(elapsed time.Duration, err error, cfg Config) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.latency == 0 {
+		n.latency = elapsed
+	} else {
+		n.latency = time.Duration(float64(n.latency)*(1-ewmaWeight) + float64(elapsed)*ewmaWeight)
+	}
+
+	if err == nil {
+		n.errRate *= 1 - ewmaWeight
+		n.failures = 0
+		return
+	}
+
+	n.errRate = n.errRate*(1-ewmaWeight) + ewmaWeight
+	n.failures++
+	if n.failures >= cfg.MaxFailures {
+		n.ejectedUntil = time.Now().Add(cfg.EjectDuration)
+		n.failures = 0
+		log.Printf("RPC 节点连续失败，剔除 %s (%s): %v", cfg.EjectDuration, n.url, err)
+	}
+}
+
+// healthy 节点当前是否可用（调用方持有锁）
+func (n *node) healthy(now time.Time) bool {
+	return !n.lagging && !now.Before(n.ejectedUntil)
+}
+
+// score 健康评分，越小越好：延迟按错误率放大
+func (n *node) score() float64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return float64(n.latency) * (1 + 4*n.errRate)
+}
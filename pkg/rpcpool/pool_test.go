@@ -0,0 +1,172 @@
+package rpcpool
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"wallet/pkg/rpcpool/rpctest"
+)
+
+// newTestPool 启动 n 个测试节点（链头均为 100）并连接
+// 健康检查间隔很长，只在 Dial 后执行一次
+func newTestPool(t *testing.T, n int, cfg Config) (*Pool, []*rpctest.Server) {
+	t.Helper()
+	var (
+		servers []*rpctest.Server
+		urls    []string
+	)
+	for i := 0; i < n; i++ {
+		s := rpctest.NewServer()
+		s.SetHead(100)
+		s.Result("eth_gasPrice", hexutil.Big(*big.NewInt(int64(i + 1))))
+		t.Cleanup(s.Close)
+		servers = append(servers, s)
+		urls = append(urls, s.URL)
+	}
+
+	cfg.HealthCheckInterval = time.Hour
+	pool, err := Dial(context.Background(), urls, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	waitFor(t, func() bool {
+		for _, s := range servers {
+			if s.Calls("eth_blockNumber") == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	return pool, servers
+}
+
+// waitFor 等待条件成立（后台健康检查是异步的）
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFailoverAndEject(t *testing.T) {
+	ctx := context.Background()
+	var failed []string
+	pool, servers := newTestPool(t, 2, Config{
+		MaxFailures:   2,
+		EjectDuration: time.Minute,
+		OnError:       func(method, endpoint string, err error) { failed = append(failed, method) },
+	})
+
+	servers[0].SetDown(true)
+	for i := 0; i < 2; i++ {
+		price, err := pool.SuggestGasPrice(ctx)
+		if err != nil || price.Int64() != 2 {
+			t.Fatalf("SuggestGasPrice = %v, %v, want 2 from the second node", price, err)
+		}
+	}
+	if len(failed) != 2 {
+		t.Errorf("OnError called %d times, want 2", len(failed))
+	}
+	if stats := pool.Stats(); stats[0].Healthy || !stats[1].Healthy {
+		t.Fatalf("first node should be ejected: %+v", stats)
+	}
+
+	// 剔除期间即使恢复也排在健康节点之后
+	servers[0].SetDown(false)
+	if price, _ := pool.SuggestGasPrice(ctx); price.Int64() != 2 {
+		t.Errorf("ejected node used: price = %v", price)
+	}
+	if n := servers[0].Calls("eth_gasPrice"); n != 0 {
+		t.Errorf("ejected node called %d times", n)
+	}
+
+	// 所有节点都故障时返回错误
+	servers[1].SetDown(true)
+	servers[0].SetDown(true)
+	if _, err := pool.SuggestGasPrice(ctx); err == nil {
+		t.Error("want error when all nodes are down")
+	}
+}
+
+func TestLaggingNodeSkipped(t *testing.T) {
+	pool, servers := newTestPool(t, 2, Config{MaxLag: 5})
+
+	servers[0].SetHead(90)
+	pool.checkHealth()
+	if stats := pool.Stats(); !stats[0].Lagging || stats[1].Lagging {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	price, err := pool.SuggestGasPrice(context.Background())
+	if err != nil || price.Int64() != 2 {
+		t.Fatalf("SuggestGasPrice = %v, %v, want 2 from the synced node", price, err)
+	}
+	if n := servers[0].Calls("eth_gasPrice"); n != 0 {
+		t.Errorf("lagging node called %d times", n)
+	}
+
+	// 追上后恢复使用
+	servers[0].SetHead(100)
+	pool.checkHealth()
+	if price, _ := pool.SuggestGasPrice(context.Background()); price.Int64() != 1 {
+		t.Errorf("recovered node not used: price = %v", price)
+	}
+}
+
+func TestExecutionErrorNoFailover(t *testing.T) {
+	pool, servers := newTestPool(t, 2, Config{})
+	servers[0].Fail("eth_call", -32000, "execution reverted")
+	servers[1].Result("eth_call", hexutil.Bytes{0x01})
+
+	to := common.HexToAddress("0x01")
+	_, err := pool.CallContract(context.Background(), ethereum.CallMsg{To: &to}, nil)
+	if err == nil || err.Error() != "execution reverted" {
+		t.Fatalf("CallContract err = %v, want execution reverted", err)
+	}
+	if n := servers[1].Calls("eth_call"); n != 0 {
+		t.Errorf("execution error should not fail over, second node called %d times", n)
+	}
+	if !pool.Stats()[0].Healthy {
+		t.Error("execution error should not count as node failure")
+	}
+}
+
+func TestSendTransactionAlreadyKnown(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.DynamicFeeTx{
+		ChainID: big.NewInt(1), Gas: 21000, GasFeeCap: big.NewInt(1), To: &common.Address{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一个节点收到交易后内部出错，换节点时交易已经在交易池中
+	pool, servers := newTestPool(t, 2, Config{})
+	servers[0].Fail("eth_sendRawTransaction", -32603, "internal error")
+	servers[1].Fail("eth_sendRawTransaction", -32000, "already known")
+	if err := pool.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("SendTransaction after failover = %v, want nil", err)
+	}
+
+	// 没有换节点时 already known 原样返回，由调用方判断
+	servers[0].Fail("eth_sendRawTransaction", -32000, "already known")
+	if err := pool.SendTransaction(context.Background(), tx); err == nil || !isAlreadyKnown(err) {
+		t.Fatalf("SendTransaction = %v, want already known", err)
+	}
+	if n := servers[1].Calls("eth_sendRawTransaction"); n != 1 {
+		t.Errorf("second node called %d times, want 1", n)
+	}
+}
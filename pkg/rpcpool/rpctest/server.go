@@ -0,0 +1,178 @@
+// Package rpctest 测试用的 JSON-RPC 节点
+//
+// 按方法名注册返回值，可以模拟节点宕机（HTTP 503）和 JSON-RPC 错误，
+// 用于 rpcpool 的故障切换测试以及扫块器等依赖 *rpcpool.Pool 的测试。
+package rpctest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HandlerFunc 处理一个方法调用，返回 *Error 时作为 JSON-RPC 错误返回
+type HandlerFunc func(params []json.RawMessage) (interface{}, error)
+
+// Error JSON-RPC 错误
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Server 测试节点
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	calls    map[string]int
+	down     atomic.Bool
+}
+
+// NewServer 启动测试节点，默认只支持 eth_chainId（返回 1）
+func NewServer() *Server {
+	s := &Server{
+		handlers: make(map[string]HandlerFunc),
+		calls:    make(map[string]int),
+	}
+	s.Result("eth_chainId", hexutil.Uint64(1))
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Handle 注册方法
+func (s *Server) Handle(method string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = fn
+}
+
+// Result 注册固定返回值的方法
+func (s *Server) Result(method string, result interface{}) {
+	s.Handle(method, func([]json.RawMessage) (interface{}, error) { return result, nil })
+}
+
+// Fail 注册固定返回 JSON-RPC 错误的方法
+func (s *Server) Fail(method string, code int, message string) {
+	s.Handle(method, func([]json.RawMessage) (interface{}, error) {
+		return nil, &Error{Code: code, Message: message}
+	})
+}
+
+// SetHead 设置 eth_blockNumber 的返回值
+func (s *Server) SetHead(n uint64) {
+	s.Result("eth_blockNumber", hexutil.Uint64(n))
+}
+
+// SetDown 模拟节点宕机：所有请求返回 HTTP 503
+func (s *Server) SetDown(down bool) {
+	s.down.Store(down)
+}
+
+// Calls 方法被调用的次数
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.down.Load() {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(body) > 0 && body[0] == '[' {
+		var reqs []request
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resps := make([]response, len(reqs))
+		for i, req := range reqs {
+			resps[i] = s.call(req)
+		}
+		json.NewEncoder(w).Encode(resps)
+		return
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(s.call(req))
+}
+
+func (s *Server) call(req request) response {
+	s.mu.Lock()
+	fn, ok := s.handlers[req.Method]
+	s.calls[req.Method]++
+	s.mu.Unlock()
+
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if !ok {
+		resp.Error = &Error{Code: -32601, Message: "the method " + req.Method + " does not exist/is not available"}
+		return resp
+	}
+	result, err := fn(req.Params)
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: -32000, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// Block 把区块编码为 eth_getBlockByNumber 的返回值（不含交易详情时 txs 为空）
+func Block(block *types.Block) map[string]interface{} {
+	out := Header(block.Header())
+	txs := make([]interface{}, 0, len(block.Transactions()))
+	for _, tx := range block.Transactions() {
+		txs = append(txs, tx)
+	}
+	out["transactions"] = txs
+	out["uncles"] = []common.Hash{}
+	return out
+}
+
+// Header 把区块头编码为 JSON 对象
+func Header(header *types.Header) map[string]interface{} {
+	data, _ := json.Marshal(header)
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
@@ -0,0 +1,52 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Keystore 加密 keystore 文件签名（geth 的 UTC--... JSON 格式）
+// 私钥只在 keystore 内部解锁，调用方拿不到明文
+type Keystore struct {
+	ks      *keystore.KeyStore
+	account accounts.Account
+}
+
+// NewKeystore 从 keystore 目录中找到 address 对应的文件并用口令解锁
+func NewKeystore(dir string, address common.Address, passphrase string) (*Keystore, error) {
+	ks := keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+	account, err := ks.Find(accounts.Account{Address: address})
+	if err != nil {
+		return nil, fmt.Errorf("find keystore for %s: %w", address.Hex(), err)
+	}
+	if err := ks.Unlock(account, passphrase); err != nil {
+		return nil, fmt.Errorf("unlock keystore %s: %w", address.Hex(), err)
+	}
+	return &Keystore{ks: ks, account: account}, nil
+}
+
+// Address 签名账户地址
+func (s *Keystore) Address() common.Address {
+	return s.account.Address
+}
+
+// SignTx 签名交易
+func (s *Keystore) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.ks.SignTx(s.account, tx, chainID)
+}
+
+// SignHash 签名哈希
+func (s *Keystore) SignHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	return s.ks.SignHash(s.account, hash[:])
+}
+
+// Lock 锁定账户（之后无法签名）
+func (s *Keystore) Lock() error {
+	return s.ks.Lock(s.account.Address)
+}
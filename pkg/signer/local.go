@@ -0,0 +1,50 @@
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Local 内存私钥签名（测试、热钱包）
+type Local struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewLocal 用私钥创建签名器
+func NewLocal(key *ecdsa.PrivateKey) *Local {
+	return &Local{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// NewLocalFromHex 用十六进制私钥（可带 0x 前缀）创建签名器
+func NewLocalFromHex(hexKey string) (*Local, error) {
+	if len(hexKey) >= 2 && hexKey[0] == '0' && (hexKey[1] == 'x' || hexKey[1] == 'X') {
+		hexKey = hexKey[2:]
+	}
+	key, err := crypto.HexToECDSA(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	return NewLocal(key), nil
+}
+
+// Address 签名账户地址
+func (s *Local) Address() common.Address {
+	return s.address
+}
+
+// SignTx 签名交易
+func (s *Local) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// SignHash 签名哈希
+func (s *Local) SignHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	return crypto.Sign(hash[:], s.key)
+}
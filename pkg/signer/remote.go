@@ -0,0 +1,100 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Remote 远程签名服务客户端（JSON-RPC）
+//
+// 签名服务独立部署在内网，私钥不出签名服务，需要实现：
+//
+//	signer_signTransaction(address, unsignedTx, chainID) -> signedTx
+//	signer_signHash(address, hash) -> signature
+//
+// unsignedTx / signedTx 为交易的二进制编码（MarshalBinary），均为 0x 开头的十六进制。
+type Remote struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+// DialRemote 连接签名服务
+func DialRemote(ctx context.Context, url string, address common.Address) (*Remote, error) {
+	client, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("dial signer %s: %w", url, err)
+	}
+	return NewRemote(client, address), nil
+}
+
+// NewRemote 用已有的 RPC 连接创建签名器
+func NewRemote(client *rpc.Client, address common.Address) *Remote {
+	return &Remote{client: client, address: address}
+}
+
+// Address 签名账户地址
+func (s *Remote) Address() common.Address {
+	return s.address
+}
+
+// SignTx 请求签名服务签名交易
+// 校验返回的交易与请求内容一致且由 address 签名，防止签名服务被篡改或返回错误交易
+func (s *Remote) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("encode transaction: %w", err)
+	}
+
+	var signedRaw hexutil.Bytes
+	if err := s.client.CallContext(ctx, &signedRaw, "signer_signTransaction", s.address, hexutil.Bytes(raw), (*hexutil.Big)(chainID)); err != nil {
+		return nil, fmt.Errorf("remote sign transaction: %w", err)
+	}
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(signedRaw); err != nil {
+		return nil, fmt.Errorf("decode signed transaction: %w", err)
+	}
+
+	txSigner := types.LatestSignerForChainID(chainID)
+	if txSigner.Hash(signed) != txSigner.Hash(tx) {
+		return nil, fmt.Errorf("remote signer returned a different transaction")
+	}
+	from, err := types.Sender(txSigner, signed)
+	if err != nil {
+		return nil, fmt.Errorf("recover signer: %w", err)
+	}
+	if from != s.address {
+		return nil, fmt.Errorf("remote signer signed with %s, want %s", from.Hex(), s.address.Hex())
+	}
+	return signed, nil
+}
+
+// SignHash 请求签名服务签名哈希
+func (s *Remote) SignHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	var sig hexutil.Bytes
+	if err := s.client.CallContext(ctx, &sig, "signer_signHash", s.address, hash); err != nil {
+		return nil, fmt.Errorf("remote sign hash: %w", err)
+	}
+	if len(sig) != crypto.SignatureLength {
+		return nil, fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	pub, err := crypto.SigToPub(hash[:], sig)
+	if err != nil {
+		return nil, fmt.Errorf("recover signer: %w", err)
+	}
+	if from := crypto.PubkeyToAddress(*pub); from != s.address {
+		return nil, fmt.Errorf("remote signer signed with %s, want %s", from.Hex(), s.address.Hex())
+	}
+	return sig, nil
+}
+
+// Close 关闭连接
+func (s *Remote) Close() {
+	s.client.Close()
+}
@@ -0,0 +1,24 @@
+// Package signer 交易签名
+//
+// Transfer 只依赖 Signer 接口，私钥可以放在本进程内存（Local）、加密的
+// keystore 文件（Keystore），或独立部署的签名服务（Remote），更换托管方式
+// 不需要修改转账逻辑。
+package signer
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Signer 交易签名器
+type Signer interface {
+	// Address 签名账户地址
+	Address() common.Address
+	// SignTx 按 chainID 签名交易，返回已签名的交易
+	SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	// SignHash 签名 32 字节哈希，返回 65 字节 [R || S || V] 签名（V 为 0/1）
+	SignHash(ctx context.Context, hash common.Hash) ([]byte, error)
+}
@@ -0,0 +1,102 @@
+package signer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// signService 测试用签名服务
+type signService struct{ local *Local }
+
+func (s *signService) SignTransaction(address common.Address, raw hexutil.Bytes, chainID *hexutil.Big) (hexutil.Bytes, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	signed, err := s.local.SignTx(context.Background(), tx, (*big.Int)(chainID))
+	if err != nil {
+		return nil, err
+	}
+	return signed.MarshalBinary()
+}
+
+func (s *signService) SignHash(address common.Address, hash common.Hash) (hexutil.Bytes, error) {
+	return s.local.SignHash(context.Background(), hash)
+}
+
+func checkSigner(t *testing.T, s Signer, want common.Address) {
+	t.Helper()
+	ctx := context.Background()
+	if s.Address() != want {
+		t.Fatalf("Address = %s, want %s", s.Address().Hex(), want.Hex())
+	}
+
+	chainID := big.NewInt(1)
+	to := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb0")
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 7, To: &to, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Value: big.NewInt(3)})
+	signed, err := s.SignTx(ctx, tx, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil || from != want {
+		t.Errorf("tx sender = %s, %v", from.Hex(), err)
+	}
+
+	hash := crypto.Keccak256Hash([]byte("wallet"))
+	sig, err := s.SignHash(ctx, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.SigToPub(hash[:], sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != want {
+		t.Errorf("hash signer mismatch: %v", err)
+	}
+}
+
+func TestSigners(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	local := NewLocal(key)
+	checkSigner(t, local, local.Address())
+
+	// keystore：用轻量 scrypt 参数生成文件，打开时参数从文件读取
+	dir := t.TempDir()
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	if _, err := ks.ImportECDSA(key, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeystore(dir, local.Address(), "wrong"); err == nil {
+		t.Error("错误口令应该解锁失败")
+	}
+	fromKeystore, err := NewKeystore(dir, local.Address(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSigner(t, fromKeystore, local.Address())
+
+	// 远程签名服务
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("signer", &signService{local: local}); err != nil {
+		t.Fatal(err)
+	}
+	remote := NewRemote(rpc.DialInProc(server), local.Address())
+	defer remote.Close()
+	checkSigner(t, remote, local.Address())
+
+	// 签名服务用了别的私钥
+	other, _ := crypto.GenerateKey()
+	wrong := NewRemote(rpc.DialInProc(server), crypto.PubkeyToAddress(other.PublicKey))
+	defer wrong.Close()
+	if _, err := wrong.SignHash(context.Background(), common.Hash{1}); err == nil {
+		t.Error("签名地址不一致时应该报错")
+	}
+}